@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	auditCreate = "create"
+	auditUpdate = "update"
+	auditDelete = "delete"
+	// auditRestore needs migration 12, which widened the action CHECK.
+	auditRestore = "restore"
+)
+
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	ItemID    int64           `json:"item_id"`
+	Action    string          `json:"action"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// recordAudit appends an audit row inside tx, so the history entry commits
+// or rolls back together with the change it describes.
+func recordAudit(ctx context.Context, tx *sql.Tx, itemID int64, action string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO audit_log (item_id, action, payload) VALUES ($1, $2, $3)`,
+		itemID,
+		action,
+		data,
+	)
+	return err
+}
+
+func (a *App) handleItemHistory(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.getItemHistory(w, r)
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, OPTIONS")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (a *App) getItemHistory(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	id, err := parseItemID(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	// Soft-deleted items keep their history; items that don't exist or
+	// belong to someone else are a 404, as in getItem.
+	var exists bool
+	if err := a.db.QueryRowContext(
+		r.Context(),
+		`SELECT EXISTS (SELECT 1 FROM items WHERE id = $1 AND `+ownerMatches("$2")+`)`,
+		id,
+		ownerFilter(r),
+	).Scan(&exists); err != nil {
+		slog.ErrorContext(r.Context(), "failed to check item", "item_id", id, "err", err)
+		writeDBError(w, err, "failed to load history")
+		return
+	}
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "item not found")
+		return
+	}
+
+	rows, err := a.db.QueryContext(
+		r.Context(),
+		`SELECT id, item_id, action, payload, created_at FROM audit_log
+		 WHERE item_id = $1
+		 ORDER BY created_at, id`,
+		id,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to query history", "item_id", id, "err", err)
+		writeDBError(w, err, "failed to load history")
+		return
+	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0, 8)
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.ItemID, &e.Action, &e.Payload, &e.CreatedAt); err != nil {
+			slog.ErrorContext(r.Context(), "failed to scan audit entry", "err", err)
+			writeDBError(w, err, "failed to load history")
+			return
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(r.Context(), "history rows error", "err", err)
+		writeDBError(w, err, "failed to load history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims is the JWT payload the API understands. Subject identifies the
+// caller; Role is optional and only "admin" carries meaning today.
+type Claims struct {
+	Role string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type claimsKey struct{}
+
+// claimsFromContext returns the claims withAuth stored for this request, or
+// nil when the request was not authenticated.
+func claimsFromContext(ctx context.Context) *Claims {
+	c, _ := ctx.Value(claimsKey{}).(*Claims)
+	return c
+}
+
+// isAdmin reports whether the caller's token carries the admin role.
+func isAdmin(c *Claims) bool {
+	return c != nil && c.Role == "admin"
+}
+
+type adminListenerKey struct{}
+
+// withAdminListener marks requests that arrived on ADMIN_LISTEN_ADDR. That
+// listener has no auth of its own and relies on its port not being
+// published, so requireAdmin trusts it.
+func withAdminListener(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminListenerKey{}, true)))
+	})
+}
+
+// isAdminRequest reports whether the request came in on the admin listener
+// or carries a token with the admin role. Unlike ownerFilter it fails
+// closed: with JWT auth disabled nobody on the public listener is an admin.
+func isAdminRequest(r *http.Request) bool {
+	if onAdmin, _ := r.Context().Value(adminListenerKey{}).(bool); onAdmin {
+		return true
+	}
+	return isAdmin(claimsFromContext(r.Context()))
+}
+
+// includeDeleted reports whether ?include_deleted=true should be honored.
+// Archived rows are for admins; anyone else silently gets live items only.
+func includeDeleted(r *http.Request) bool {
+	return queryBool(r, "include_deleted") && isAdminRequest(r)
+}
+
+// requireAdmin answers 403 unless isAdminRequest.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !isAdminRequest(r) {
+		writeJSONError(w, http.StatusForbidden, "admin role required")
+		return false
+	}
+	return true
+}
+
+// adminOnly guards a handler that doesn't call requireAdmin itself, such as
+// /metrics and pprof when they share the public listener.
+func adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requireAdmin(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// requestOwner is the owner_id stamped on items the caller creates: the JWT
+// subject, or NULL when authentication is disabled.
+func requestOwner(r *http.Request) sql.NullString {
+	c := claimsFromContext(r.Context())
+	if c == nil || c.Subject == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: c.Subject, Valid: true}
+}
+
+// ownerFilter is the owner item queries are scoped to. It is NULL, meaning
+// no scoping, for admins and when authentication is disabled.
+func ownerFilter(r *http.Request) sql.NullString {
+	c := claimsFromContext(r.Context())
+	if c == nil || isAdmin(c) {
+		return sql.NullString{}
+	}
+	// A token without a subject still gets scoped; it just owns nothing.
+	return sql.NullString{String: c.Subject, Valid: true}
+}
+
+// ownerMatches is the SQL predicate pairing with an ownerFilter argument
+// bound at placeholder p. Items owned by others simply don't match, so
+// handlers answer 404 instead of revealing that the item exists.
+func ownerMatches(p string) string {
+	return `(` + p + `::text IS NULL OR owner_id = ` + p + `)`
+}
+
+// publicPaths stay reachable without a token so orchestrator probes keep
+// working.
+var publicPaths = map[string]bool{
+	"/api/health": true,
+	"/api/live":   true,
+	"/api/ready":  true,
+}
+
+type apiKeyAuthKey struct{}
+
+// hasValidAPIKey reports whether withAPIKey accepted an X-API-Key for this
+// request.
+func hasValidAPIKey(r *http.Request) bool {
+	ok, _ := r.Context().Value(apiKeyAuthKey{}).(bool)
+	return ok
+}
+
+// withAuth requires a valid HS256 bearer token signed with secret on every
+// route except publicPaths. A request withAPIKey already accepted is let
+// through without one, since keys are the token-free option for services;
+// it carries no claims, so it is unscoped and not an admin.
+func withAuth(next http.Handler, secret []byte) http.Handler {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	keyFunc := func(*jwt.Token) (any, error) { return secret, nil }
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] || hasValidAPIKey(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || raw == "" {
+			writeUnauthorized(w, "missing bearer token")
+			return
+		}
+
+		var claims Claims
+		if _, err := parser.ParseWithClaims(raw, &claims, keyFunc); err != nil {
+			writeUnauthorized(w, "invalid or expired token")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, &claims)))
+	})
+}
+
+// withAPIKey requires an X-API-Key header matching one of keys on requests
+// for which protect returns true; publicPaths are always let through. Pass
+// isWriteRequest as protect to leave reads public. A valid key is recorded
+// in the context either way, for withAuth.
+func withAPIKey(next http.Handler, keys []string, protect func(*http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if validAPIKey(r.Header.Get("X-API-Key"), keys) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyAuthKey{}, true)))
+			return
+		}
+		if publicPaths[r.URL.Path] || !protect(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeUnauthorized(w, "missing or invalid API key")
+	})
+}
+
+// validAPIKey compares got against every key in constant time, without
+// returning early, so response timing reveals nothing about which key or
+// prefix matched.
+func validAPIKey(got string, keys []string) bool {
+	if got == "" {
+		return false
+	}
+	match := 0
+	for _, k := range keys {
+		match |= subtle.ConstantTimeCompare([]byte(got), []byte(k))
+	}
+	return match == 1
+}
+
+func allRequests(*http.Request) bool { return true }
+
+// isWriteRequest reports whether r can modify state.
+func isWriteRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+func writeUnauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	writeJSONError(w, http.StatusUnauthorized, msg)
+}
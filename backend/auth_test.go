@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAdminOnlyFailsClosed(t *testing.T) {
+	h := adminOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name string
+		ctx  func(context.Context) context.Context
+		want int
+	}{
+		{"no auth", func(ctx context.Context) context.Context { return ctx }, http.StatusForbidden},
+		{"non-admin token", func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, claimsKey{}, &Claims{Role: "user"})
+		}, http.StatusForbidden},
+		{"admin token", func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, claimsKey{}, &Claims{Role: "admin"})
+		}, http.StatusOK},
+		{"admin listener", func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, adminListenerKey{}, true)
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req = req.WithContext(tt.ctx(req.Context()))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+// With both JWT_SECRET and API_KEYS set, main wires withAPIKey around
+// withAuth with a protect that never rejects; either credential suffices.
+func TestAPIKeyOrJWT(t *testing.T) {
+	secret := []byte("test-secret")
+	never := func(*http.Request) bool { return false }
+	h := withAPIKey(withAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), secret), []string{"k1"}, never)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "u1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"api key only", "X-API-Key", "k1", http.StatusOK},
+		{"token only", "Authorization", "Bearer " + token, http.StatusOK},
+		{"wrong key", "X-API-Key", "nope", http.StatusUnauthorized},
+		{"neither", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
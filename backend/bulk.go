@@ -0,0 +1,485 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxBulkItems caps a single bulk request; larger imports should be split.
+const maxBulkItems = 1000
+
+// maxFetchIDs caps GET /api/items?ids=, which also has to fit in a URL.
+const maxFetchIDs = 100
+
+func (a *App) handleBulkItems(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.createItemsBulk(w, r)
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// createItemsBulk inserts every item in one multi-row INSERT inside a
+// transaction. A single invalid entry rejects the whole batch.
+func (a *App) createItemsBulk(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	defer r.Body.Close()
+
+	var reqs []createItemRequest
+	if !a.decodeJSON(w, r, &reqs) {
+		return
+	}
+
+	if len(reqs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "at least one item is required")
+		return
+	}
+	if len(reqs) > maxBulkItems {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch exceeds %d items", maxBulkItems))
+		return
+	}
+
+	// $1 is the owner shared by every row.
+	values := make([]string, 0, len(reqs))
+	args := make([]any, 0, 1+3*len(reqs))
+	args = append(args, requestOwner(r))
+	var errs ValidationErrors
+	tags := make([][]string, len(reqs))
+	for i, req := range reqs {
+		prefix := fmt.Sprintf("[%d].", i)
+		v := a.validateItem(&errs, prefix, itemInput{Title: &req.Title, Description: &req.Description, Tags: req.Tags}, false)
+		createdAt := parseCreatedAt(&errs, prefix+"created_at", req.CreatedAt)
+		tags[i] = v.Tags
+		args = append(args, *v.Title, *v.Description, createdAt)
+		n := len(args)
+		// updated_at is now() even when created_at is backdated; see
+		// insertItemSQL.
+		values = append(values, fmt.Sprintf(
+			"($%d, NULLIF($%d, ''), $1, COALESCE($%d::timestamptz, now()), now())",
+			n-2, n-1, n,
+		))
+	}
+	if !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	var items []Item
+	err := a.inTx(r.Context(), func(tx *sql.Tx) error {
+		items = make([]Item, 0, len(reqs))
+		rows, err := tx.QueryContext(
+			r.Context(),
+			`INSERT INTO items (title, description, owner_id, created_at, updated_at) VALUES `+strings.Join(values, ", ")+` RETURNING `+itemColumns,
+			args...,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var it Item
+			if err := scanItem(rows, &it); err != nil {
+				return err
+			}
+			items = append(items, it)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for i := range items {
+			items[i].Tags = tags[i]
+			if err := addItemTags(r.Context(), tx, items[i].ID, items[i].Tags); err != nil {
+				return err
+			}
+			if err := recordAudit(r.Context(), tx, items[i].ID, auditCreate, items[i]); err != nil {
+				return err
+			}
+			if err := notifyItemEvent(r.Context(), tx, auditCreate, items[i].ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeJSONError(w, http.StatusConflict, errDuplicateTitle)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to bulk insert items", "err", err)
+		writeDBError(w, err, "failed to create items")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+type deleteItemsRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+type deleteItemsResponse struct {
+	Deleted  int     `json:"deleted"`
+	NotFound []int64 `json:"not_found,omitempty"`
+}
+
+// deleteItemsBulk soft-deletes every listed item in one transaction, the
+// same way deleteItem archives a single one. IDs that don't exist, are
+// already deleted or belong to someone else are reported in not_found
+// rather than failing the batch.
+func (a *App) deleteItemsBulk(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	defer r.Body.Close()
+
+	var req deleteItemsRequest
+	if !a.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "at least one id is required")
+		return
+	}
+	if len(req.IDs) > maxBulkItems {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch exceeds %d ids", maxBulkItems))
+		return
+	}
+
+	var errs ValidationErrors
+	ids := checkIDs(&errs, req.IDs)
+	if !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	var items []Item
+	err := a.inTx(r.Context(), func(tx *sql.Tx) error {
+		items = nil
+		rows, err := tx.QueryContext(
+			r.Context(),
+			`UPDATE items SET deleted_at = now(), version = version + 1
+			 WHERE id = ANY($1::bigint[]) AND deleted_at IS NULL AND `+ownerMatches("$2")+` RETURNING `+itemColumns,
+			ids,
+			ownerFilter(r),
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var it Item
+			if err := scanItem(rows, &it); err != nil {
+				return err
+			}
+			items = append(items, it)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for _, it := range items {
+			if err := recordAudit(r.Context(), tx, it.ID, auditDelete, it); err != nil {
+				return err
+			}
+			if err := notifyItemEvent(r.Context(), tx, auditDelete, it.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to bulk delete items", "err", err)
+		writeDBError(w, err, "failed to delete items")
+		return
+	}
+
+	deleted := make(map[int64]bool, len(items))
+	for _, it := range items {
+		deleted[it.ID] = true
+	}
+	resp := deleteItemsResponse{Deleted: len(items)}
+	for _, id := range ids {
+		if !deleted[id] {
+			resp.NotFound = append(resp.NotFound, id)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// checkIDs validates the ids of a bulk request, dropping duplicates but
+// keeping first-seen order.
+func checkIDs(errs *ValidationErrors, raw []int64) []int64 {
+	ids := make([]int64, 0, len(raw))
+	seen := make(map[int64]bool, len(raw))
+	for i, id := range raw {
+		if id <= 0 {
+			errs.Add(fmt.Sprintf("ids[%d]", i), "must be a positive integer")
+			continue
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (a *App) handleBulkTag(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.tagItemsBulk(w, r)
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+type tagItemsRequest struct {
+	IDs []int64 `json:"ids"`
+	Tag string  `json:"tag"`
+}
+
+type tagItemsResponse struct {
+	Tagged   int     `json:"tagged"`
+	NotFound []int64 `json:"not_found,omitempty"`
+	// TagLimit lists items left untagged because they already carry
+	// maxTagsPerItem tags.
+	TagLimit []int64 `json:"tag_limit,omitempty"`
+}
+
+// tagItemsBulk adds one tag to every listed item in one transaction. Items
+// that gain the tag get a new version, like any other edit; ones that
+// already had it are left alone and not counted. IDs that don't exist, are
+// deleted or belong to someone else are reported in not_found.
+func (a *App) tagItemsBulk(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	defer r.Body.Close()
+
+	var req tagItemsRequest
+	if !a.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "at least one id is required")
+		return
+	}
+	if len(req.IDs) > maxBulkItems {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch exceeds %d ids", maxBulkItems))
+		return
+	}
+
+	var errs ValidationErrors
+	ids := checkIDs(&errs, req.IDs)
+	a.itemSchema().tag.check(&errs, "tag", req.Tag)
+	if !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+	tag := normalizeTags([]string{req.Tag})[0]
+
+	var items []Item
+	var resp tagItemsResponse
+	err := a.inTx(r.Context(), func(tx *sql.Tx) error {
+		items, resp = nil, tagItemsResponse{}
+
+		// Lock the rows first so the tag count can't change under us.
+		rows, err := tx.QueryContext(
+			r.Context(),
+			`SELECT id,
+			        EXISTS (SELECT 1 FROM item_tags it JOIN tags t ON t.id = it.tag_id
+			                WHERE it.item_id = items.id AND t.name = $3),
+			        (SELECT COUNT(*) FROM item_tags it WHERE it.item_id = items.id)
+			 FROM items
+			 WHERE id = ANY($1::bigint[]) AND deleted_at IS NULL AND `+ownerMatches("$2")+`
+			 FOR UPDATE`,
+			ids,
+			ownerFilter(r),
+			tag,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		found := make(map[int64]bool, len(ids))
+		var toTag []int64
+		for rows.Next() {
+			var id int64
+			var hasTag bool
+			var tagCount int
+			if err := rows.Scan(&id, &hasTag, &tagCount); err != nil {
+				return err
+			}
+			found[id] = true
+			switch {
+			case hasTag:
+			case tagCount >= maxTagsPerItem:
+				resp.TagLimit = append(resp.TagLimit, id)
+			default:
+				toTag = append(toTag, id)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			if !found[id] {
+				resp.NotFound = append(resp.NotFound, id)
+			}
+		}
+		if len(toTag) == 0 {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(
+			r.Context(),
+			`INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`,
+			tag,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(
+			r.Context(),
+			`INSERT INTO item_tags (item_id, tag_id)
+			 SELECT unnest($1::bigint[]), id FROM tags WHERE name = $2
+			 ON CONFLICT DO NOTHING`,
+			toTag,
+			tag,
+		); err != nil {
+			return err
+		}
+
+		// A separate statement, so itemColumns already sees the new tag.
+		rows, err = tx.QueryContext(
+			r.Context(),
+			`UPDATE items SET updated_at = now(), version = version + 1
+			 WHERE id = ANY($1::bigint[]) RETURNING `+itemColumns,
+			toTag,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var it Item
+			if err := scanItem(rows, &it); err != nil {
+				return err
+			}
+			items = append(items, it)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for _, it := range items {
+			if err := recordAudit(r.Context(), tx, it.ID, auditUpdate, it); err != nil {
+				return err
+			}
+			if err := notifyItemEvent(r.Context(), tx, auditUpdate, it.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to bulk tag items", "tag", tag, "err", err)
+		writeDBError(w, err, "failed to tag items")
+		return
+	}
+	resp.Tagged = len(items)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// parseIDList parses a comma-separated ?ids= value, dropping duplicates but
+// keeping first-seen order.
+func parseIDList(raw string) ([]int64, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxFetchIDs {
+		return nil, fmt.Errorf("ids accepts at most %d ids", maxFetchIDs)
+	}
+	ids := make([]int64, 0, len(parts))
+	seen := make(map[int64]bool, len(parts))
+	for _, p := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("ids must be a comma-separated list of positive integers, got %q", p)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// getItemsByIDs answers GET /api/items?ids=1,2,3 with the items among them
+// the caller can see, in the order requested. Unknown ids are simply
+// absent, so hydrating a stale id list doesn't fail as a whole.
+func (a *App) getItemsByIDs(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	ids, err := parseIDList(r.URL.Query().Get("ids"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var items []Item
+	err = a.retryRead(r.Context(), "fetch items", func() error {
+		var err error
+		items, err = a.queryItems(
+			r.Context(),
+			`SELECT `+itemColumns+` FROM items
+			 WHERE id = ANY($1::bigint[]) AND ($2 OR deleted_at IS NULL) AND `+ownerMatches("$3")+`
+			 ORDER BY array_position($1::bigint[], id::bigint)`,
+			ids,
+			includeDeleted(r),
+			ownerFilter(r),
+		)
+		return err
+	})
+	if err != nil {
+		if clientGone(r) {
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to fetch items by id", "err", err)
+		writeDBError(w, err, "failed to load items")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// itemsLastModified is the latest change to any item visible to owner.
+// Filters are deliberately ignored: deleting an item moves deleted_at but
+// takes it out of the filtered set, so only the unfiltered maximum is sure
+// to move on every change.
+func (a *App) itemsLastModified(ctx context.Context, owner sql.NullString) (time.Time, error) {
+	var t sql.NullTime
+	err := a.db.QueryRowContext(
+		ctx,
+		`SELECT max(greatest(updated_at, deleted_at)) FROM items WHERE `+ownerMatches("$1"),
+		owner,
+	).Scan(&t)
+	return t.Time, err
+}
+
+// writeListCaching sets Cache-Control and Last-Modified on a list response
+// and answers 304 if nothing changed since If-Modified-Since, reporting
+// whether it did.
+func (a *App) writeListCaching(w http.ResponseWriter, r *http.Request, lastModified time.Time) bool {
+	cc := "no-cache"
+	if a.listCacheMaxAge > 0 {
+		cc = "max-age=" + strconv.Itoa(int(a.listCacheMaxAge.Seconds()))
+	}
+	if a.privateReads {
+		// Per-user or key-protected results must not be served to anyone
+		// else by a CDN.
+		cc = "private, " + cc
+	}
+	w.Header().Set("Cache-Control", cc)
+
+	if lastModified.IsZero() {
+		return false
+	}
+	// HTTP dates have second precision.
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is every setting the backend reads at startup. Values come from
+// the defaults in defaultConfig, then the optional CONFIG_FILE, then the
+// environment, each layer overriding the one before it.
+type Config struct {
+	// AppEnv is "production" to enable the stricter checks in validateConfig.
+	AppEnv string `json:"app_env" yaml:"app_env"`
+	// LogLevel is debug, info, warn or error; LogFormat is text or json.
+	LogLevel  string `json:"log_level" yaml:"log_level"`
+	LogFormat string `json:"log_format" yaml:"log_format"`
+
+	// DatabaseURL, when set, is used as-is and the DB_HOST..DB_SSLMODE
+	// fields are ignored.
+	DatabaseURL string `json:"database_url" yaml:"database_url"`
+
+	DBHost            string   `json:"db_host" yaml:"db_host"`
+	DBPort            string   `json:"db_port" yaml:"db_port"`
+	DBUser            string   `json:"db_user" yaml:"db_user"`
+	DBPassword        string   `json:"db_password" yaml:"db_password"`
+	DBName            string   `json:"db_name" yaml:"db_name"`
+	DBSSLMode         string   `json:"db_sslmode" yaml:"db_sslmode"`
+	DBMaxOpenConns    int      `json:"db_max_open_conns" yaml:"db_max_open_conns"`
+	DBMaxIdleConns    int      `json:"db_max_idle_conns" yaml:"db_max_idle_conns"`
+	DBConnMaxLifetime Duration `json:"db_conn_max_lifetime" yaml:"db_conn_max_lifetime"`
+	DBConnectTimeout  Duration `json:"db_connect_timeout" yaml:"db_connect_timeout"`
+	// DBHealthInterval is how often the background ping behind /api/ready
+	// runs.
+	DBHealthInterval Duration `json:"db_health_interval" yaml:"db_health_interval"`
+	// DBRetries is how many times transient read errors and serialization
+	// failures are retried; 0 disables retrying.
+	DBRetries int `json:"db_retries" yaml:"db_retries"`
+	// DBStatementTimeoutMS makes Postgres cancel any statement running
+	// longer than this many milliseconds; 0 leaves the server default.
+	DBStatementTimeoutMS int `json:"db_statement_timeout_ms" yaml:"db_statement_timeout_ms"`
+
+	// DefaultPageSize is the list limit when ?limit= is absent; requests
+	// above MaxPageSize are clamped to it.
+	DefaultPageSize int `json:"default_page_size" yaml:"default_page_size"`
+	MaxPageSize     int `json:"max_page_size" yaml:"max_page_size"`
+	// ListCacheMaxAge is the Cache-Control max-age on list responses; 0
+	// sends no-cache so clients always revalidate.
+	ListCacheMaxAge Duration `json:"list_cache_max_age" yaml:"list_cache_max_age"`
+
+	TitleMaxLength int      `json:"title_max_length" yaml:"title_max_length"`
+	MaxBodyBytes   int      `json:"max_body_bytes" yaml:"max_body_bytes"`
+	QueryTimeout   Duration `json:"query_timeout" yaml:"query_timeout"`
+	// SlowQueryMS logs statements taking at least this many milliseconds;
+	// 0 disables the log.
+	SlowQueryMS int `json:"slow_query_ms" yaml:"slow_query_ms"`
+	// IdempotencyKeyTTL is how long an Idempotency-Key is remembered.
+	IdempotencyKeyTTL Duration `json:"idempotency_key_ttl" yaml:"idempotency_key_ttl"`
+
+	CORSAllowedOrigins   []string `json:"cors_allowed_origins" yaml:"cors_allowed_origins"`
+	CORSAllowCredentials bool     `json:"cors_allow_credentials" yaml:"cors_allow_credentials"`
+	// CORSMaxAge is how long browsers may cache a preflight response.
+	CORSMaxAge Duration `json:"cors_max_age" yaml:"cors_max_age"`
+	// TrustedProxies are the CIDRs whose X-Forwarded-For and X-Real-IP
+	// headers are believed. Empty means none are, and the TCP peer is the
+	// client.
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+
+	JWTSecret string `json:"jwt_secret" yaml:"jwt_secret"`
+	// APIKeys are accepted in X-API-Key. With JWTSecret also set, a valid
+	// key or a valid bearer token is enough, and APIKeyScope has no effect.
+	APIKeys     []string `json:"api_keys" yaml:"api_keys"`
+	APIKeyScope string   `json:"api_key_scope" yaml:"api_key_scope"`
+	ReadOnly    bool     `json:"read_only" yaml:"read_only"`
+	// MaintenanceMode is the initial state of the runtime maintenance flag.
+	MaintenanceMode bool `json:"maintenance_mode" yaml:"maintenance_mode"`
+	EnablePprof     bool `json:"enable_pprof" yaml:"enable_pprof"`
+	// StartupSelfTest runs startupSelfTest after migrating and refuses to
+	// start if it fails.
+	StartupSelfTest bool `json:"startup_selftest" yaml:"startup_selftest"`
+	// MigrateDryRun prints the pending migrations and exits without
+	// applying them or serving anything.
+	MigrateDryRun bool `json:"migrate_dry_run" yaml:"migrate_dry_run"`
+
+	RateLimitPerMinute int `json:"rate_limit_per_minute" yaml:"rate_limit_per_minute"`
+	// RateLimitBurst defaults to RateLimitPerMinute when zero.
+	RateLimitBurst int `json:"rate_limit_burst" yaml:"rate_limit_burst"`
+	// NotFoundBlockThreshold is how many unrouted 404s an IP may hit within
+	// NotFoundWindow before it is refused with 403 for NotFoundBlockDuration;
+	// 0 only counts them.
+	NotFoundBlockThreshold int      `json:"not_found_block_threshold" yaml:"not_found_block_threshold"`
+	NotFoundWindow         Duration `json:"not_found_window" yaml:"not_found_window"`
+	NotFoundBlockDuration  Duration `json:"not_found_block_duration" yaml:"not_found_block_duration"`
+
+	ListenAddr string `json:"listen_addr" yaml:"listen_addr"`
+	// BasePath prefixes every public route, e.g. "/backend". loadConfig
+	// normalizes it to a leading slash and no trailing one.
+	BasePath string `json:"base_path" yaml:"base_path"`
+	// TrailingSlash is strip, require or off; see withTrailingSlash.
+	TrailingSlash         string   `json:"trailing_slash" yaml:"trailing_slash"`
+	HTTPReadHeaderTimeout Duration `json:"http_read_header_timeout" yaml:"http_read_header_timeout"`
+	HTTPReadTimeout       Duration `json:"http_read_timeout" yaml:"http_read_timeout"`
+	HTTPWriteTimeout      Duration `json:"http_write_timeout" yaml:"http_write_timeout"`
+	HTTPIdleTimeout       Duration `json:"http_idle_timeout" yaml:"http_idle_timeout"`
+	// RequestTimeout caps a whole non-streaming API request; 0 disables it.
+	RequestTimeout Duration `json:"request_timeout" yaml:"request_timeout"`
+	TLSCertFile    string   `json:"tls_cert_file" yaml:"tls_cert_file"`
+	TLSKeyFile     string   `json:"tls_key_file" yaml:"tls_key_file"`
+	// AdminListenAddr, when set, moves /metrics, /debug/pprof/ and
+	// /api/debug/dbstats onto a separate listener.
+	AdminListenAddr string `json:"admin_listen_addr" yaml:"admin_listen_addr"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" in config
+// files.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func defaultConfig() Config {
+	return Config{
+		LogLevel:  "info",
+		LogFormat: "text",
+
+		DBHost:            "localhost",
+		DBPort:            "5432",
+		DBUser:            "app",
+		DBPassword:        "secret",
+		DBName:            "appdb",
+		DBSSLMode:         "disable",
+		DBMaxOpenConns:    10,
+		DBMaxIdleConns:    5,
+		DBConnMaxLifetime: Duration{30 * time.Minute},
+		DBConnectTimeout:  Duration{60 * time.Second},
+		DBHealthInterval:  Duration{5 * time.Second},
+		DBRetries:         2,
+
+		DefaultPageSize: defaultPageLimit,
+		MaxPageSize:     maxPageLimit,
+		ListCacheMaxAge: Duration{10 * time.Second},
+
+		TitleMaxLength: defaultMaxTitleLen,
+		MaxBodyBytes:   defaultMaxBodyBytes,
+		QueryTimeout:   Duration{5 * time.Second},
+		SlowQueryMS:    500,
+
+		IdempotencyKeyTTL: Duration{24 * time.Hour},
+
+		CORSMaxAge: Duration{600 * time.Second},
+
+		APIKeyScope: "all",
+
+		RateLimitPerMinute: 100,
+
+		NotFoundWindow:        Duration{time.Minute},
+		NotFoundBlockDuration: Duration{10 * time.Minute},
+
+		ListenAddr:            ":8080",
+		TrailingSlash:         trailingSlashStrip,
+		HTTPReadHeaderTimeout: Duration{2 * time.Second},
+		HTTPReadTimeout:       Duration{5 * time.Second},
+		HTTPWriteTimeout:      Duration{10 * time.Second},
+		HTTPIdleTimeout:       Duration{60 * time.Second},
+		// Under HTTPWriteTimeout, so the 503 can still be written.
+		RequestTimeout: Duration{8 * time.Second},
+	}
+}
+
+// loadConfig builds the startup Config. A CONFIG_FILE ending in .yaml or
+// .yml is parsed as YAML, anything else as JSON; unknown keys are rejected
+// so a typo doesn't silently fall back to a default.
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("read config file: %w", err)
+		}
+		if err := decodeConfigFile(path, data, &cfg); err != nil {
+			return cfg, fmt.Errorf("parse %s: %w", path, err)
+		}
+		slog.Info("loaded config", "path", path)
+	}
+
+	cfg.applyEnv()
+	if cfg.RateLimitBurst <= 0 {
+		cfg.RateLimitBurst = cfg.RateLimitPerMinute
+	}
+	cfg.BasePath = normalizeBasePath(cfg.BasePath)
+	return cfg, nil
+}
+
+func decodeConfigFile(path string, data []byte, cfg *Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		return dec.Decode(cfg)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		return dec.Decode(cfg)
+	}
+}
+
+// applyEnv overrides cfg with every variable that is set, using the current
+// field value as the default.
+func (c *Config) applyEnv() {
+	c.AppEnv = getEnvOrFile("APP_ENV", c.AppEnv)
+	c.LogLevel = getEnvOrFile("LOG_LEVEL", c.LogLevel)
+	c.LogFormat = getEnvOrFile("LOG_FORMAT", c.LogFormat)
+
+	c.DatabaseURL = getEnvOrFile("DATABASE_URL", c.DatabaseURL)
+	c.DBHost = getEnvOrFile("DB_HOST", c.DBHost)
+	c.DBPort = getEnvOrFile("DB_PORT", c.DBPort)
+	c.DBUser = getEnvOrFile("DB_USER", c.DBUser)
+	c.DBPassword = getEnvOrFile("DB_PASSWORD", c.DBPassword)
+	c.DBName = getEnvOrFile("DB_NAME", c.DBName)
+	c.DBSSLMode = getEnvOrFile("DB_SSLMODE", c.DBSSLMode)
+	c.DBMaxOpenConns = getEnvInt("DB_MAX_OPEN_CONNS", c.DBMaxOpenConns)
+	c.DBMaxIdleConns = getEnvInt("DB_MAX_IDLE_CONNS", c.DBMaxIdleConns)
+	c.DBConnMaxLifetime.Duration = getEnvDuration("DB_CONN_MAX_LIFETIME", c.DBConnMaxLifetime.Duration)
+	c.DBConnectTimeout.Duration = getEnvDuration("DB_CONNECT_TIMEOUT", c.DBConnectTimeout.Duration)
+	c.DBHealthInterval.Duration = getEnvDuration("DB_HEALTH_INTERVAL", c.DBHealthInterval.Duration)
+	c.DBRetries = getEnvInt("DB_RETRIES", c.DBRetries)
+	c.DBStatementTimeoutMS = getEnvInt("DB_STATEMENT_TIMEOUT", c.DBStatementTimeoutMS)
+
+	c.DefaultPageSize = getEnvInt("DEFAULT_PAGE_SIZE", c.DefaultPageSize)
+	c.MaxPageSize = getEnvInt("MAX_PAGE_SIZE", c.MaxPageSize)
+	c.ListCacheMaxAge.Duration = getEnvDuration("LIST_CACHE_MAX_AGE", c.ListCacheMaxAge.Duration)
+
+	c.TitleMaxLength = getEnvInt("TITLE_MAX_LENGTH", c.TitleMaxLength)
+	c.MaxBodyBytes = getEnvInt("MAX_BODY_BYTES", c.MaxBodyBytes)
+	c.QueryTimeout.Duration = getEnvDuration("QUERY_TIMEOUT", c.QueryTimeout.Duration)
+	c.SlowQueryMS = getEnvInt("SLOW_QUERY_MS", c.SlowQueryMS)
+	c.IdempotencyKeyTTL.Duration = getEnvDuration("IDEMPOTENCY_KEY_TTL", c.IdempotencyKeyTTL.Duration)
+
+	c.CORSAllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS", c.CORSAllowedOrigins)
+	c.TrustedProxies = getEnvList("TRUSTED_PROXIES", c.TrustedProxies)
+	c.CORSAllowCredentials = getEnvBool("CORS_ALLOW_CREDENTIALS", c.CORSAllowCredentials)
+	c.CORSMaxAge.Duration = getEnvDuration("CORS_MAX_AGE", c.CORSMaxAge.Duration)
+
+	c.JWTSecret = getEnvOrFile("JWT_SECRET", c.JWTSecret)
+	c.APIKeys = getEnvList("API_KEYS", c.APIKeys)
+	c.APIKeyScope = getEnvOrFile("API_KEY_SCOPE", c.APIKeyScope)
+	c.ReadOnly = getEnvBool("READ_ONLY", c.ReadOnly)
+	c.MaintenanceMode = getEnvBool("MAINTENANCE_MODE", c.MaintenanceMode)
+	c.EnablePprof = getEnvBool("ENABLE_PPROF", c.EnablePprof)
+	c.StartupSelfTest = getEnvBool("STARTUP_SELFTEST", c.StartupSelfTest)
+	c.MigrateDryRun = getEnvBool("MIGRATE_DRY_RUN", c.MigrateDryRun)
+
+	c.RateLimitPerMinute = getEnvInt("RATE_LIMIT_PER_MINUTE", c.RateLimitPerMinute)
+	c.RateLimitBurst = getEnvInt("RATE_LIMIT_BURST", c.RateLimitBurst)
+	c.NotFoundBlockThreshold = getEnvInt("NOT_FOUND_BLOCK_THRESHOLD", c.NotFoundBlockThreshold)
+	c.NotFoundWindow.Duration = getEnvDuration("NOT_FOUND_WINDOW", c.NotFoundWindow.Duration)
+	c.NotFoundBlockDuration.Duration = getEnvDuration("NOT_FOUND_BLOCK_DURATION", c.NotFoundBlockDuration.Duration)
+
+	c.ListenAddr = getEnvOrFile("LISTEN_ADDR", c.ListenAddr)
+	c.BasePath = getEnvOrFile("BASE_PATH", c.BasePath)
+	c.TrailingSlash = getEnvOrFile("TRAILING_SLASH", c.TrailingSlash)
+	c.HTTPReadHeaderTimeout.Duration = getEnvDuration("HTTP_READ_HEADER_TIMEOUT", c.HTTPReadHeaderTimeout.Duration)
+	c.HTTPReadTimeout.Duration = getEnvDuration("HTTP_READ_TIMEOUT", c.HTTPReadTimeout.Duration)
+	c.HTTPWriteTimeout.Duration = getEnvDuration("HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout.Duration)
+	c.HTTPIdleTimeout.Duration = getEnvDuration("HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout.Duration)
+	c.RequestTimeout.Duration = getEnvDuration("REQUEST_TIMEOUT", c.RequestTimeout.Duration)
+	c.TLSCertFile = getEnvOrFile("TLS_CERT_FILE", c.TLSCertFile)
+	c.TLSKeyFile = getEnvOrFile("TLS_KEY_FILE", c.TLSKeyFile)
+	c.AdminListenAddr = getEnvOrFile("ADMIN_LISTEN_ADDR", c.AdminListenAddr)
+}
+
+// normalizeBasePath turns "backend", "/backend/" and "/backend" into
+// "/backend", and "/" into "".
+func normalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// minJWTSecretLen is the shortest JWT_SECRET accepted in production; HS256
+// keys shorter than the hash output are easy to brute-force.
+const minJWTSecretLen = 32
+
+// validateConfig returns every problem with cfg rather than stopping at the
+// first, so one failed start shows everything that needs fixing. In
+// production it also rejects the demo defaults that are fine for
+// docker-compose but must never reach the swarm.
+func validateConfig(cfg Config) []string {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if err := validateListenAddr(cfg.ListenAddr); err != nil {
+		addf("LISTEN_ADDR %q: %v", cfg.ListenAddr, err)
+	}
+	if cfg.AdminListenAddr != "" {
+		if err := validateListenAddr(cfg.AdminListenAddr); err != nil {
+			addf("ADMIN_LISTEN_ADDR %q: %v", cfg.AdminListenAddr, err)
+		} else if cfg.AdminListenAddr == cfg.ListenAddr {
+			addf("ADMIN_LISTEN_ADDR must differ from LISTEN_ADDR")
+		}
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		addf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.DBHealthInterval.Duration <= 0 {
+		addf("DB_HEALTH_INTERVAL must be positive, got %s", cfg.DBHealthInterval)
+	}
+	if strings.ContainsAny(cfg.BasePath, "?#{} ") {
+		addf("BASE_PATH %q must be a plain path", cfg.BasePath)
+	}
+	switch cfg.TrailingSlash {
+	case trailingSlashStrip, trailingSlashRequire, trailingSlashOff:
+	default:
+		addf("TRAILING_SLASH must be strip, require or off, got %q", cfg.TrailingSlash)
+	}
+	if cfg.DBRetries < 0 {
+		addf("DB_RETRIES must not be negative, got %d", cfg.DBRetries)
+	}
+	if cfg.DBStatementTimeoutMS < 0 {
+		addf("DB_STATEMENT_TIMEOUT must not be negative, got %d", cfg.DBStatementTimeoutMS)
+	}
+	if cfg.MaxPageSize <= 0 {
+		addf("MAX_PAGE_SIZE must be positive, got %d", cfg.MaxPageSize)
+	}
+	if cfg.DefaultPageSize <= 0 || cfg.DefaultPageSize > cfg.MaxPageSize {
+		addf("DEFAULT_PAGE_SIZE must be between 1 and MAX_PAGE_SIZE (%d), got %d", cfg.MaxPageSize, cfg.DefaultPageSize)
+	}
+	if cfg.NotFoundBlockThreshold < 0 {
+		addf("NOT_FOUND_BLOCK_THRESHOLD must not be negative, got %d", cfg.NotFoundBlockThreshold)
+	}
+	if cfg.NotFoundBlockThreshold > 0 && (cfg.NotFoundWindow.Duration <= 0 || cfg.NotFoundBlockDuration.Duration <= 0) {
+		addf("NOT_FOUND_WINDOW and NOT_FOUND_BLOCK_DURATION must be positive when NOT_FOUND_BLOCK_THRESHOLD is set")
+	}
+	if cfg.CORSMaxAge.Duration < 0 {
+		addf("CORS_MAX_AGE must not be negative, got %s", cfg.CORSMaxAge)
+	}
+	if _, err := parseTrustedProxies(cfg.TrustedProxies); err != nil {
+		addf("TRUSTED_PROXIES: %v", err)
+	}
+	if cfg.ListCacheMaxAge.Duration < 0 {
+		addf("LIST_CACHE_MAX_AGE must not be negative, got %s", cfg.ListCacheMaxAge)
+	}
+	if cfg.TitleMaxLength <= 0 {
+		addf("TITLE_MAX_LENGTH must be positive, got %d", cfg.TitleMaxLength)
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		addf("MAX_BODY_BYTES must be positive, got %d", cfg.MaxBodyBytes)
+	}
+	if cfg.QueryTimeout.Duration <= 0 {
+		addf("QUERY_TIMEOUT must be positive, got %s", cfg.QueryTimeout)
+	}
+	if cfg.RequestTimeout.Duration < 0 {
+		addf("REQUEST_TIMEOUT must not be negative, got %s", cfg.RequestTimeout)
+	} else if w := cfg.HTTPWriteTimeout.Duration; cfg.RequestTimeout.Duration > 0 && w > 0 && cfg.RequestTimeout.Duration >= w {
+		addf("REQUEST_TIMEOUT (%s) must be shorter than HTTP_WRITE_TIMEOUT (%s) or the 503 is never sent", cfg.RequestTimeout, cfg.HTTPWriteTimeout)
+	}
+	if cfg.SlowQueryMS < 0 {
+		addf("SLOW_QUERY_MS must not be negative, got %d", cfg.SlowQueryMS)
+	}
+	if cfg.DatabaseURL != "" {
+		if u, err := url.Parse(cfg.DatabaseURL); err != nil {
+			// url's error quotes the URL, password included.
+			addf("DATABASE_URL is not a valid URL")
+		} else if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			addf("DATABASE_URL must use the postgres:// or postgresql:// scheme, got %q", u.Scheme)
+		} else if u.Host == "" {
+			addf("DATABASE_URL has no host")
+		}
+	}
+	if cfg.IdempotencyKeyTTL.Duration <= 0 {
+		addf("IDEMPOTENCY_KEY_TTL must be positive, got %s", cfg.IdempotencyKeyTTL)
+	}
+	if cfg.APIKeyScope != "all" && cfg.APIKeyScope != "writes" {
+		addf("API_KEY_SCOPE must be \"all\" or \"writes\", got %q", cfg.APIKeyScope)
+	}
+
+	if cfg.AppEnv != "production" {
+		return problems
+	}
+	defaults := defaultConfig()
+	if cfg.DatabaseURL != "" {
+		if u, err := url.Parse(cfg.DatabaseURL); err == nil {
+			if pw, _ := u.User.Password(); pw == "" || pw == defaults.DBPassword {
+				addf("DATABASE_URL password is empty or left at the insecure default")
+			}
+		}
+	} else if cfg.DBPassword == "" || cfg.DBPassword == defaults.DBPassword {
+		addf("DB_PASSWORD is empty or left at the insecure default")
+	}
+	if cfg.JWTSecret == "" {
+		addf("JWT_SECRET is empty; authentication would be disabled")
+	} else if len(cfg.JWTSecret) < minJWTSecretLen {
+		addf("JWT_SECRET is shorter than %d bytes", minJWTSecretLen)
+	}
+	return problems
+}
+
+// dsn is the pgx connection string for the configured database, preferring
+// DATABASE_URL over the individual DB_* settings.
+func (c *Config) dsn() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
+	}
+	return fmt.Sprintf(
+		"postgres://%s:%s@%s:%s/%s?sslmode=%s",
+		url.QueryEscape(c.DBUser),
+		url.QueryEscape(c.DBPassword),
+		c.DBHost,
+		c.DBPort,
+		c.DBName,
+		c.DBSSLMode,
+	)
+}
+
+func getEnvOrFile(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+
+	if filePath := os.Getenv(key + "_FILE"); filePath != "" {
+		data, err := os.ReadFile(filePath)
+		if err == nil {
+			s := strings.TrimSpace(string(data))
+			if s != "" {
+				return s
+			}
+		}
+	}
+
+	return def
+}
+
+// getEnvInt is getEnvOrFile for integers. A malformed value is logged and
+// replaced by def rather than aborting startup.
+func getEnvInt(key string, def int) int {
+	raw := getEnvOrFile(key, "")
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		slog.Warn("invalid setting, using default", "key", key, "value", raw, "default", def)
+		return def
+	}
+	return v
+}
+
+// getEnvDuration is getEnvOrFile for time.ParseDuration strings such as "30m".
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	raw := getEnvOrFile(key, "")
+	if raw == "" {
+		return def
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		slog.Warn("invalid setting, using default", "key", key, "value", raw, "default", def)
+		return def
+	}
+	return v
+}
+
+// getEnvBool is getEnvOrFile for strconv.ParseBool values.
+func getEnvBool(key string, def bool) bool {
+	raw := getEnvOrFile(key, "")
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		slog.Warn("invalid setting, using default", "key", key, "value", raw, "default", def)
+		return def
+	}
+	return v
+}
+
+// getEnvList is getEnvOrFile for comma-separated lists.
+func getEnvList(key string, def []string) []string {
+	if raw := getEnvOrFile(key, ""); raw != "" {
+		return splitList(raw)
+	}
+	return def
+}
+
+// splitList parses a comma-separated env value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// itemCursor marks the last item of a page in (created_at, id) order, the
+// keyset that cursor pagination walks.
+type itemCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func encodeCursor(it Item) string {
+	raw := it.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(it.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (itemCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return itemCursor{}, errInvalidCursor
+	}
+	ts, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return itemCursor{}, errInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return itemCursor{}, errInvalidCursor
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return itemCursor{}, errInvalidCursor
+	}
+	return itemCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// itemPage is the list response shape in cursor mode. NextCursor is empty
+// on the last page.
+type itemPage struct {
+	Items      []Item `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// listEnvelope is the list response shape with ?envelope=true, the same in
+// both pagination modes. New metadata goes in Page without breaking Data.
+type listEnvelope struct {
+	Data []Item   `json:"data"`
+	Page pageInfo `json:"page"`
+}
+
+type pageInfo struct {
+	Limit int `json:"limit"`
+	// Offset is always 0 in cursor mode.
+	Offset     int    `json:"offset"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
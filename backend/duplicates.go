@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// errPossibleDuplicate aborts a ?check_duplicates=true create that found a
+// likely duplicate, so the transaction rolls back before anything is
+// inserted.
+var errPossibleDuplicate = errors.New("possible duplicate title")
+
+// duplicateTitleResponse is the 409 body for a likely duplicate. It extends
+// the usual error shape with the id of the item already using the title.
+type duplicateTitleResponse struct {
+	Error      string `json:"error"`
+	ExistingID int64  `json:"existing_id"`
+}
+
+// findSimilarTitle returns the id of a live item visible to owner whose
+// title matches title ignoring case, or 0 if there is none. Exact matches
+// are already rejected by the unique index; this catches the near misses it
+// lets through.
+func findSimilarTitle(ctx context.Context, tx *sql.Tx, owner sql.NullString, title string) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT id FROM items WHERE lower(title) = lower($1) AND deleted_at IS NULL AND `+ownerMatches("$2")+` ORDER BY id LIMIT 1`,
+		title,
+		owner,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+func writeDuplicateWarning(w http.ResponseWriter, existingID int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusConflict)
+	_ = json.NewEncoder(w).Encode(duplicateTitleResponse{
+		Error:      "a similar item already exists; retry with force=true to create it anyway",
+		ExistingID: existingID,
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// itemEventsChannel is the Postgres NOTIFY channel carrying item changes as
+// "<action>:<id>". Only the id is sent: payloads are capped at 8000 bytes
+// and consumers must re-read the row under the right owner scope anyway.
+const itemEventsChannel = "item_events"
+
+// itemEvent is one change to an item; Action is one of the audit actions.
+type itemEvent struct {
+	Action string
+	ID     int64
+}
+
+// notifyItemEvent queues a notification inside tx; Postgres delivers it
+// only if tx commits.
+func notifyItemEvent(ctx context.Context, tx *sql.Tx, action string, id int64) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, itemEventsChannel, action+":"+strconv.FormatInt(id, 10))
+	return err
+}
+
+func parseItemEvent(payload string) (itemEvent, error) {
+	action, idStr, ok := strings.Cut(payload, ":")
+	if !ok {
+		return itemEvent{}, fmt.Errorf("malformed payload %q", payload)
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return itemEvent{}, fmt.Errorf("malformed payload %q", payload)
+	}
+	return itemEvent{Action: action, ID: id}, nil
+}
+
+// itemBroker fans notifications from a single LISTEN connection out to every
+// in-process subscriber, so streaming clients don't each hold a DB
+// connection. Because every replica listens, clients see inserts made
+// through any replica.
+type itemBroker struct {
+	mu   sync.Mutex
+	subs map[chan itemEvent]struct{}
+}
+
+func newItemBroker() *itemBroker {
+	return &itemBroker{subs: make(map[chan itemEvent]struct{})}
+}
+
+func (b *itemBroker) subscribe() chan itemEvent {
+	ch := make(chan itemEvent, 16)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *itemBroker) unsubscribe(ch chan itemEvent) {
+	b.mu.Lock()
+	delete(b.subs, ch)
+	b.mu.Unlock()
+}
+
+// publish never blocks: a subscriber that has fallen behind misses events
+// rather than stalling everyone else.
+func (b *itemBroker) publish(ev itemEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// run holds a dedicated pgx connection LISTENing on itemEventsChannel and
+// reconnects with backoff until ctx is cancelled. The backoff only grows
+// across attempts that never got as far as LISTEN, so a connection that
+// drops after working is retried quickly and few notifications are missed.
+func (b *itemBroker) run(ctx context.Context, dsn string) {
+	const initialBackoff = time.Second
+	backoff := initialBackoff
+	for {
+		listened, err := b.listen(ctx, dsn)
+		if ctx.Err() != nil {
+			return
+		}
+		if listened {
+			backoff = initialBackoff
+		}
+		slog.Warn("item listener stopped; reconnecting", "err", err, "backoff", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// listen receives notifications until the connection fails. listened
+// reports whether it got as far as LISTEN.
+func (b *itemBroker) listen(ctx context.Context, dsn string) (listened bool, err error) {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+itemEventsChannel); err != nil {
+		return false, err
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		ev, err := parseItemEvent(n.Payload)
+		if err != nil {
+			slog.Warn("ignoring notification", "channel", itemEventsChannel, "err", err)
+			continue
+		}
+		b.publish(ev)
+	}
+}
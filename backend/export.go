@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var exportCSVHeader = []string{"id", "title", "description", "tags", "owner_id", "created_at", "updated_at", "deleted_at"}
+
+func (a *App) handleExport(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.exportItems(w, r)
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, OPTIONS")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// exportItems streams every visible item straight from the DB cursor, so
+// memory use doesn't grow with the table. The list filters (?q=, ?tag=,
+// ?from=, ?to=, ?include_deleted=) apply here too.
+func (a *App) exportItems(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		writeJSONError(w, http.StatusBadRequest, `format must be "json" or "csv"`)
+		return
+	}
+
+	// Large exports can take longer than the server's WriteTimeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.WarnContext(r.Context(), "failed to clear write deadline for export", "err", err)
+	}
+
+	q, err := itemFilters(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, err := a.db.QueryContext(
+		r.Context(),
+		`SELECT `+itemColumns+` FROM items`+q.whereSQL()+` ORDER BY id`,
+		q.args...,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to query items for export", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to export items")
+		return
+	}
+	defer rows.Close()
+
+	filename := "items-" + time.Now().UTC().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	// Once the first row is out the status is committed, so failures past
+	// this point can only be logged and the stream cut short.
+	var write func(Item) error
+	var finish func() error
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportCSVHeader); err != nil {
+			return
+		}
+		write = func(it Item) error { return cw.Write(itemCSVRecord(it)) }
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		n := 0
+		_, _ = w.Write([]byte("["))
+		write = func(it Item) error {
+			if n > 0 {
+				if _, err := w.Write([]byte(",")); err != nil {
+					return err
+				}
+			}
+			n++
+			return enc.Encode(it)
+		}
+		finish = func() error {
+			_, err := w.Write([]byte("]\n"))
+			return err
+		}
+	}
+
+	for rows.Next() {
+		var it Item
+		if err := scanItem(rows, &it); err != nil {
+			slog.ErrorContext(r.Context(), "failed to scan item during export", "err", err)
+			return
+		}
+		if err := write(it); err != nil {
+			slog.WarnContext(r.Context(), "export aborted", "err", err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(r.Context(), "export rows error", "err", err)
+		return
+	}
+	if err := finish(); err != nil {
+		slog.ErrorContext(r.Context(), "failed to finish export", "err", err)
+	}
+}
+
+func itemCSVRecord(it Item) []string {
+	deletedAt := ""
+	if it.DeletedAt != nil {
+		deletedAt = it.DeletedAt.Format(time.RFC3339Nano)
+	}
+	return []string{
+		strconv.FormatInt(it.ID, 10),
+		it.Title,
+		it.Description,
+		strings.Join(it.Tags, ";"),
+		it.OwnerID,
+		it.CreatedAt.Format(time.RFC3339Nano),
+		it.UpdatedAt.Format(time.RFC3339Nano),
+		deletedAt,
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// gzipMinSize is the smallest body worth compressing; below roughly one
+// packet the gzip framing costs more than it saves.
+const gzipMinSize = 1400
+
+// withGzip compresses responses for clients that advertise gzip support.
+// Bodies are buffered until gzipMinSize bytes so small responses go out
+// untouched. It sits inside withLogging so the logged size is the wire size.
+func withGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		// Upgraded connections (WebSocket) must reach the raw writer to hijack it.
+		if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer func() {
+			// On panic, leave the response uncommitted so withRecover can
+			// still send its 500.
+			if rec := recover(); rec != nil {
+				panic(rec)
+			}
+			gw.Close()
+		}()
+
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// alreadyCompressed reports content types that gzip can't shrink further.
+func alreadyCompressed(contentType string) bool {
+	switch {
+	case strings.HasPrefix(contentType, "image/"),
+		strings.HasPrefix(contentType, "video/"),
+		strings.HasPrefix(contentType, "audio/"),
+		strings.Contains(contentType, "zip"),
+		strings.Contains(contentType, "compressed"):
+		return true
+	}
+	return false
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.status != 0 {
+		return
+	}
+	g.status = code
+	if code == http.StatusNoContent || code == http.StatusNotModified || code < http.StatusOK {
+		g.decide(false)
+	}
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if g.status == 0 {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.decided {
+		if g.gz != nil {
+			return g.gz.Write(b)
+		}
+		return g.ResponseWriter.Write(b)
+	}
+
+	g.buf = append(g.buf, b...)
+	if len(g.buf) >= gzipMinSize {
+		if err := g.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide commits the headers and flushes anything buffered, compressed or
+// not. compress is only a preference; the content type can veto it.
+func (g *gzipResponseWriter) decide(compress bool) error {
+	if g.decided {
+		return nil
+	}
+	g.decided = true
+
+	h := g.Header()
+	if compress && h.Get("Content-Encoding") == "" && !alreadyCompressed(h.Get("Content-Type")) &&
+		// Event streams are flushed per message, where gzip gains nothing.
+		!strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+
+	if len(g.buf) == 0 {
+		return nil
+	}
+	buf := g.buf
+	g.buf = nil
+	if g.gz != nil {
+		_, err := g.gz.Write(buf)
+		return err
+	}
+	_, err := g.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush commits to compression so streaming handlers aren't held back by
+// the buffer, then pushes pending bytes to the client.
+func (g *gzipResponseWriter) Flush() {
+	_ = g.decide(true)
+	if g.gz != nil {
+		_ = g.gz.Flush()
+	}
+	_ = http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+func (g *gzipResponseWriter) Close() {
+	if !g.decided {
+		if g.status == 0 && len(g.buf) == 0 {
+			// Handler wrote nothing; let net/http send its implicit 200.
+			return
+		}
+		_ = g.decide(false)
+	}
+	if g.gz != nil {
+		_ = g.gz.Close()
+	}
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
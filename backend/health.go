@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// errSchemaBehind means the database answers but is missing migrations this
+// binary expects, e.g. a new image rolled out against an unmigrated DB.
+var errSchemaBehind = errors.New("schema is behind")
+
+// dbHealth is the last known state of the database, refreshed by run so
+// readiness probes don't each cost a round trip.
+type dbHealth struct {
+	mu  sync.RWMutex
+	err error
+}
+
+// newDBHealth starts out healthy: main only builds it after waitForDB and
+// migrate succeeded.
+func newDBHealth() *dbHealth {
+	return &dbHealth{}
+}
+
+// status is what /api/ready reports: "ok", "schema_behind" or "down".
+func (h *dbHealth) status() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	switch {
+	case h.err == nil:
+		return "ok"
+	case errors.Is(h.err, errSchemaBehind):
+		return "schema_behind"
+	}
+	return "down"
+}
+
+func (h *dbHealth) set(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case err != nil && (h.err == nil || h.err.Error() != err.Error()):
+		slog.Warn("DB health check failed", "err", err)
+	case err == nil && h.err != nil:
+		slog.Info("DB health check recovered")
+	}
+	h.err = err
+}
+
+// checkSchema fails with errSchemaBehind unless the newest migration this
+// binary knows about has been applied. A query error also covers what a
+// plain ping would catch.
+func checkSchema(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT coalesce(max(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	if want := latestMigrationVersion(); version < want {
+		return fmt.Errorf("%w: at version %d, want %d", errSchemaBehind, version, want)
+	}
+	return nil
+}
+
+// run checks db every interval until ctx is cancelled. A failed query makes
+// database/sql discard the broken connection, so the next tick dials a
+// fresh one; that is all the reconnecting the pool needs.
+func (h *dbHealth) run(ctx context.Context, db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, min(interval, 2*time.Second))
+			err := checkSchema(pingCtx, db)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			h.set(err)
+		}
+	}
+}
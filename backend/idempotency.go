@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// maxIdempotencyKeyLen bounds the Idempotency-Key header; clients normally
+// send a UUID.
+const maxIdempotencyKeyLen = 255
+
+// lookupIdempotencyKey returns the item a previous request with the same
+// key created, or ok=false if the key is new or expired. It takes a
+// transaction-scoped advisory lock on the key first, so a concurrent retry
+// waits for the original request to commit instead of inserting a twin.
+func (a *App) lookupIdempotencyKey(ctx context.Context, tx *sql.Tx, owner sql.NullString, key string) (Item, bool, error) {
+	if _, err := tx.ExecContext(
+		ctx,
+		`SELECT pg_advisory_xact_lock(hashtextextended($1 || ':' || $2, 0))`,
+		owner.String,
+		key,
+	); err != nil {
+		return Item{}, false, err
+	}
+
+	var item Item
+	err := scanItem(tx.QueryRowContext(
+		ctx,
+		`SELECT `+itemColumns+` FROM items WHERE id = (
+			SELECT item_id FROM idempotency_keys
+			 WHERE owner_id = $1 AND key = $2 AND created_at > now() - make_interval(secs => $3)
+		)`,
+		owner.String,
+		key,
+		a.idempotencyTTL.Seconds(),
+	), &item)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Item{}, false, nil
+	}
+	if err != nil {
+		return Item{}, false, err
+	}
+	return item, true, nil
+}
+
+// saveIdempotencyKey records that key produced itemID, replacing an expired
+// entry for the same key if one is still around.
+func saveIdempotencyKey(ctx context.Context, tx *sql.Tx, owner sql.NullString, key string, itemID int64) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO idempotency_keys (owner_id, key, item_id) VALUES ($1, $2, $3)
+		 ON CONFLICT (owner_id, key) DO UPDATE SET item_id = EXCLUDED.item_id, created_at = now()`,
+		owner.String,
+		key,
+		itemID,
+	)
+	return err
+}
+
+// purgeIdempotencyKeys deletes expired keys every hour until ctx is
+// cancelled. Lookups already ignore them; this only keeps the table small.
+func (a *App) purgeIdempotencyKeys(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := a.db.ExecContext(
+				ctx,
+				`DELETE FROM idempotency_keys WHERE created_at <= now() - make_interval(secs => $1)`,
+				a.idempotencyTTL.Seconds(),
+			)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("failed to purge idempotency keys", "err", err)
+				}
+				continue
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				slog.Info("purged expired idempotency keys", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type importRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type importSummary struct {
+	Created int              `json:"created"`
+	Skipped int              `json:"skipped"`
+	Failed  []importRowError `json:"failed"`
+}
+
+func (a *App) handleImport(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.importItems(w, r)
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// importItems creates one item per CSV row. The file needs a header row
+// with a "title" column; "description", "tags" (";"-separated) and
+// "created_at" (RFC3339, to keep historical timestamps) are optional, which
+// makes an export file importable as-is. Rows that fail
+// validation or reuse a live title are reported and skipped, the rest
+// commit together.
+func (a *App) importItems(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	r.Body = http.MaxBytesReader(w, r.Body, a.maxBodyBytes)
+
+	body, err := importBody(r)
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+
+	records, err := csv.NewReader(body).ReadAll()
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+	if len(records) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "CSV is empty")
+		return
+	}
+
+	header := records[0]
+	titleCol := columnIndex(header, "title")
+	if titleCol < 0 {
+		writeJSONError(w, http.StatusBadRequest, `CSV header must include a "title" column`)
+		return
+	}
+	descCol := columnIndex(header, "description")
+	tagsCol := columnIndex(header, "tags")
+	createdCol := columnIndex(header, "created_at")
+
+	skipDuplicates := queryBool(r, "skip_duplicates")
+	owner := requestOwner(r)
+	var summary importSummary
+
+	// Started after the upload is parsed, so a slow client doesn't use up
+	// the database's time.
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	err = a.inTx(r.Context(), func(tx *sql.Tx) error {
+		summary = importSummary{Failed: []importRowError{}}
+		seen := make(map[string]bool)
+		for i, rec := range records[1:] {
+			// Row numbers are 1-based and count the header, matching what a
+			// spreadsheet shows.
+			row := i + 2
+
+			var tags []string
+			if raw := field(rec, tagsCol); raw != "" {
+				tags = strings.Split(raw, ";")
+			}
+			title, description := field(rec, titleCol), field(rec, descCol)
+
+			var errs ValidationErrors
+			v := a.validateItem(&errs, "", itemInput{Title: &title, Description: &description, Tags: tags}, false)
+			createdAt := parseCreatedAt(&errs, "created_at", field(rec, createdCol))
+			if !errs.Empty() {
+				summary.Failed = append(summary.Failed, importRowError{Row: row, Error: errs.Error()})
+				continue
+			}
+			title = *v.Title
+
+			if skipDuplicates {
+				dup := seen[title]
+				if !dup {
+					// Not owner-scoped: items_title_key is global.
+					if err := tx.QueryRowContext(
+						r.Context(),
+						`SELECT EXISTS (SELECT 1 FROM items WHERE title = $1 AND deleted_at IS NULL)`,
+						title,
+					).Scan(&dup); err != nil {
+						return err
+					}
+				}
+				seen[title] = true
+				if dup {
+					summary.Skipped++
+					continue
+				}
+			}
+
+			// The savepoint lets a taken title fail just this row instead
+			// of aborting the transaction.
+			if _, err := tx.ExecContext(r.Context(), `SAVEPOINT import_row`); err != nil {
+				return err
+			}
+			if _, err := a.insertItem(r.Context(), tx, owner, title, *v.Description, v.Tags, createdAt); err != nil {
+				if !isUniqueViolation(err) {
+					return err
+				}
+				if _, err := tx.ExecContext(r.Context(), `ROLLBACK TO SAVEPOINT import_row`); err != nil {
+					return err
+				}
+				summary.Failed = append(summary.Failed, importRowError{Row: row, Error: errDuplicateTitle})
+				continue
+			}
+			if _, err := tx.ExecContext(r.Context(), `RELEASE SAVEPOINT import_row`); err != nil {
+				return err
+			}
+			summary.Created++
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to import items", "err", err)
+		writeDBError(w, err, "failed to import items")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(summary)
+}
+
+// importBody returns the CSV payload, accepting either a raw text/csv body
+// or a multipart upload in the "file" field.
+func importBody(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("multipart upload needs a \"file\" field: %w", err)
+	}
+	return file, nil
+}
+
+func writeImportError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, "malformed CSV: "+err.Error())
+}
+
+func columnIndex(header []string, name string) int {
+	return slices.IndexFunc(header, func(h string) bool {
+		return strings.EqualFold(strings.TrimSpace(h), name)
+	})
+}
+
+// field returns rec[i], or "" when the column is absent.
+func field(rec []string, i int) string {
+	if i < 0 || i >= len(rec) {
+		return ""
+	}
+	return rec[i]
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// JSON:API (https://jsonapi.org) output, opted into with
+// Accept: application/vnd.api+json. Only the shapes live here; handlers
+// pick the format and otherwise behave exactly as for plain JSON.
+
+const jsonAPIContentType = "application/vnd.api+json"
+
+const jsonAPIItemType = "items"
+
+func wantsJSONAPI(r *http.Request) bool {
+	return accepts(r, jsonAPIContentType)
+}
+
+// jsonAPIItemAttributes is Item minus id, which JSON:API hoists to the
+// resource object as a string.
+type jsonAPIItemAttributes struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Tags        []string   `json:"tags"`
+	OwnerID     string     `json:"owner_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Version     int64      `json:"version"`
+}
+
+type jsonAPIResource struct {
+	Type       string                `json:"type"`
+	ID         string                `json:"id"`
+	Attributes jsonAPIItemAttributes `json:"attributes"`
+	Links      map[string]string     `json:"links"`
+}
+
+type jsonAPIDocument struct {
+	Data  any               `json:"data"`
+	Meta  map[string]any    `json:"meta,omitempty"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+func (a *App) jsonAPIItem(it Item) jsonAPIResource {
+	return jsonAPIResource{
+		Type: jsonAPIItemType,
+		ID:   strconv.FormatInt(it.ID, 10),
+		Attributes: jsonAPIItemAttributes{
+			Title:       it.Title,
+			Description: it.Description,
+			Tags:        it.Tags,
+			OwnerID:     it.OwnerID,
+			CreatedAt:   it.CreatedAt,
+			UpdatedAt:   it.UpdatedAt,
+			DeletedAt:   it.DeletedAt,
+			Version:     it.Version,
+		},
+		Links: map[string]string{"self": a.itemLocation(it.ID)},
+	}
+}
+
+func writeJSONAPI(w http.ResponseWriter, doc jsonAPIDocument) {
+	w.Header().Set("Content-Type", jsonAPIContentType)
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+func (a *App) writeJSONAPIItem(w http.ResponseWriter, it Item) {
+	writeJSONAPI(w, jsonAPIDocument{Data: a.jsonAPIItem(it)})
+}
+
+// writeJSONAPIList renders a list page with the total in meta. links
+// carries the same next/prev URLs as the Link header.
+func (a *App) writeJSONAPIList(w http.ResponseWriter, items []Item, total int64, links map[string]string) {
+	data := make([]jsonAPIResource, len(items))
+	for i, it := range items {
+		data[i] = a.jsonAPIItem(it)
+	}
+	writeJSONAPI(w, jsonAPIDocument{Data: data, Meta: map[string]any{"total": total}, Links: links})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// pageLink is r's URL with the given query parameters replaced, as a
+// base-path-absolute reference.
+func (a *App) pageLink(r *http.Request, set map[string]string) string {
+	q := r.URL.Query()
+	for k, v := range set {
+		q.Set(k, v)
+	}
+	u := url.URL{Path: a.basePath + r.URL.Path, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// setPageLinks emits an RFC 8288 Link header for the list page just
+// served and returns the same links by rel. Offset pages get next and prev;
+// cursor pages only get next, since a keyset cursor can't be walked
+// backwards.
+func (a *App) setPageLinks(w http.ResponseWriter, r *http.Request, limit, offset, count int, total int64, nextCursor string, cursorMode bool) map[string]string {
+	var links []string
+	byRel := make(map[string]string, 2)
+	add := func(rel string, set map[string]string) {
+		link := a.pageLink(r, set)
+		byRel[rel] = link
+		links = append(links, `<`+link+`>; rel="`+rel+`"`)
+	}
+
+	limitStr := strconv.Itoa(limit)
+	if cursorMode {
+		if nextCursor != "" {
+			add("next", map[string]string{"cursor": nextCursor, "limit": limitStr})
+		}
+	} else {
+		if int64(offset+count) < total {
+			add("next", map[string]string{"offset": strconv.Itoa(offset + limit), "limit": limitStr})
+		}
+		if offset > 0 {
+			add("prev", map[string]string{"offset": strconv.Itoa(max(offset-limit, 0)), "limit": limitStr})
+		}
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	return byRel
+}
@@ -2,229 +2,1395 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
 	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type App struct {
 	db *sql.DB
+
+	// maxTitleLen caps titles in runes, not bytes.
+	maxTitleLen int
+	// maxBodyBytes caps request bodies on write endpoints.
+	maxBodyBytes int64
+	// queryTimeout bounds the DB work of a single request.
+	queryTimeout time.Duration
+	// basePath is the BASE_PATH prefix every public route is served under.
+	basePath string
+	// dbRetries is how many times a transient DB failure is retried.
+	dbRetries int
+	// pageSize and maxPageSize are the list limit when none is given and
+	// the most a client may ask for.
+	pageSize    int
+	maxPageSize int
+	// listCacheMaxAge is the max-age listItems advertises.
+	listCacheMaxAge time.Duration
+	// production is APP_ENV=production; it disables destructive dev tools.
+	production bool
+	// privateReads is set when reads need a JWT or API key, so cacheable
+	// responses must be marked private.
+	privateReads bool
+	// idempotencyTTL is how long createItem remembers an Idempotency-Key.
+	idempotencyTTL time.Duration
+
+	stmts    *preparedStmts
+	dbHealth *dbHealth
+	// maintenance, when set, makes withMaintenance answer 503.
+	maintenance atomic.Bool
+	events      *itemBroker
+	hub         *wsHub
+	stats       *statsCache
+
+	// draining is closed once shutdown starts so long-lived streams end
+	// instead of holding srv.Shutdown until its timeout.
+	draining  chan struct{}
+	drainOnce sync.Once
+}
+
+// drain tells streaming handlers to say goodbye and return. It is safe to
+// call more than once.
+func (a *App) drain() {
+	a.drainOnce.Do(func() { close(a.draining) })
 }
 
 type Item struct {
-	ID        int64     `json:"id"`
-	Title     string    `json:"title"`
-	CreatedAt time.Time `json:"created_at"`
+	XMLName     xml.Name   `json:"-" xml:"item"`
+	ID          int64      `json:"id" xml:"id"`
+	Title       string     `json:"title" xml:"title"`
+	Description string     `json:"description" xml:"description"`
+	Tags        []string   `json:"tags" xml:"tags>tag"`
+	OwnerID     string     `json:"owner_id,omitempty" xml:"owner_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" xml:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" xml:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+	// Version starts at 1 and is bumped by every change; updates must send
+	// the version they read.
+	Version int64 `json:"version" xml:"version"`
+}
+
+// itemsXML is the XML form of a list response; pagination travels in the
+// X-Total-Count and Link headers as with the bare JSON array.
+type itemsXML struct {
+	XMLName xml.Name `xml:"items"`
+	Items   []Item
+}
+
+// itemColumns is the column list every query returning an Item selects, in
+// the order scanItem expects.
+const itemColumns = `id, title, COALESCE(description, ''), ` + itemTagsColumn + `,
+	COALESCE(owner_id, ''), created_at, updated_at, deleted_at, version`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanItem scans the itemColumns of a row into it. Queries selecting more
+// than itemColumns pass destinations for the trailing columns as extra.
+func scanItem(s rowScanner, it *Item, extra ...any) error {
+	var tags []byte
+	dest := []any{
+		&it.ID, &it.Title, &it.Description, &tags,
+		&it.OwnerID, &it.CreatedAt, &it.UpdatedAt, &it.DeletedAt, &it.Version,
+	}
+	if err := s.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	return json.Unmarshal(tags, &it.Tags)
 }
 
 type createItemRequest struct {
-	Title string `json:"title"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	// CreatedAt backdates the item, for migrating existing data. On the
+	// single-item endpoint it is admin-only.
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+type updateItemRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     *int64 `json:"version"`
 }
 
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+
+	defaultMaxTitleLen  = 256
+	defaultMaxBodyBytes = 1 << 20
+
+	shutdownTimeout = 10 * time.Second
+)
+
 func main() {
-	dsn := buildDSNFromEnv()
-	db, err := sql.Open("pgx", dsn)
+	cfg, err := loadConfig()
 	if err != nil {
-		log.Fatalf("failed to open DB: %v", err)
+		fatal("failed to load config", "err", err)
 	}
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(30 * time.Minute)
+	logger, err := newLogger(cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fatal("invalid config", "problem", err.Error())
+	}
+	// Also routes the standard log package, used by dependencies, through
+	// logger.
+	slog.SetDefault(logger)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	if problems := validateConfig(cfg); len(problems) > 0 {
+		for _, p := range problems {
+			slog.Error("invalid config", "problem", p)
+		}
+		fatal("refusing to start with invalid settings", "count", len(problems))
+	}
+
+	dsn := cfg.dsn()
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		fatal("failed to parse DB config", "err", err)
+	}
+	connConfig.Tracer = &queryTracer{slow: time.Duration(cfg.SlowQueryMS) * time.Millisecond}
+	if cfg.DBStatementTimeoutMS > 0 {
+		// Sent in the startup packet, so every pooled connection gets it
+		// and the server cancels runaway queries even if a context doesn't.
+		connConfig.RuntimeParams["statement_timeout"] = strconv.Itoa(cfg.DBStatementTimeoutMS)
+		slog.Info("applying statement_timeout to DB connections", "statement_timeout_ms", cfg.DBStatementTimeoutMS)
+	}
+	db := stdlib.OpenDB(*connConfig)
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime.Duration)
+
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "appdb"))
 
-	if err := db.PingContext(ctx); err != nil {
-		log.Fatalf("failed to ping DB: %v", err)
+	if err := waitForDB(db, cfg.DBConnectTimeout.Duration); err != nil {
+		fatal("failed to ping DB", "err", err)
 	}
 
+	if cfg.MigrateDryRun {
+		if err := migrateDryRun(db, os.Stdout); err != nil {
+			fatal("failed to list pending migrations", "err", err)
+		}
+		return
+	}
 	if err := migrate(db); err != nil {
-		log.Fatalf("failed to run migrate: %v", err)
+		fatal("failed to run migrate", "err", err)
 	}
 
-	app := &App{db: db}
+	if cfg.StartupSelfTest {
+		if err := startupSelfTest(db); err != nil {
+			fatal("startup self-test failed; check the DB role's grants", "err", err)
+		}
+	}
+
+	app := &App{
+		db:              db,
+		maxTitleLen:     cfg.TitleMaxLength,
+		maxBodyBytes:    int64(cfg.MaxBodyBytes),
+		queryTimeout:    cfg.QueryTimeout.Duration,
+		idempotencyTTL:  cfg.IdempotencyKeyTTL.Duration,
+		basePath:        cfg.BasePath,
+		dbRetries:       cfg.DBRetries,
+		pageSize:        cfg.DefaultPageSize,
+		maxPageSize:     cfg.MaxPageSize,
+		listCacheMaxAge: cfg.ListCacheMaxAge.Duration,
+		production:      cfg.AppEnv == "production",
+		privateReads:    cfg.JWTSecret != "" || (len(cfg.APIKeys) > 0 && cfg.APIKeyScope != "writes"),
+		stmts:           prepareStmts(context.Background(), db),
+		dbHealth:        newDBHealth(),
+		events:          newItemBroker(),
+		stats:           newStatsCache(),
+		draining:        make(chan struct{}),
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/health", app.handleHealth)
+	mux.HandleFunc("/api/live", app.handleLive)
+	mux.HandleFunc("/api/ready", app.handleReady)
+	mux.HandleFunc("/api/version", app.handleVersion)
+	mux.HandleFunc("/api/openapi.json", app.handleOpenAPI)
 	mux.HandleFunc("/api/items", app.handleItems)
+	mux.HandleFunc("/api/items/{id}", app.handleItem)
+	mux.HandleFunc("/api/items/bulk", app.handleBulkItems)
+	mux.HandleFunc("/api/items/tag", app.handleBulkTag)
+	mux.HandleFunc("/api/items/export", app.handleExport)
+	mux.HandleFunc("/api/items/import", app.handleImport)
+	mux.HandleFunc("/api/items/stream", app.streamItems)
+	mux.HandleFunc("/api/items/count", app.countItems)
+	mux.HandleFunc("/api/items/search", app.searchItems)
+	mux.HandleFunc("/api/items/recent", app.recentItems)
+	mux.HandleFunc("/api/tags", app.listTags)
+	mux.HandleFunc("/api/stats", app.handleStats)
+	mux.HandleFunc("/api/ws", app.serveWS)
+	mux.HandleFunc("/api/items/{id}/history", app.handleItemHistory)
+	mux.HandleFunc("/api/items/{id}/restore", app.handleRestore)
+
+	// The operational endpoints move to their own listener when
+	// ADMIN_LISTEN_ADDR is set, and otherwise share the public mux, where
+	// requireAdmin limits them to admin tokens. The handlers that aren't
+	// ours get that check from adminOnly.
+	adminMux := mux
+	if cfg.AdminListenAddr != "" {
+		adminMux = http.NewServeMux()
+	} else {
+		slog.Warn("ADMIN_LISTEN_ADDR is empty; /metrics and the admin endpoints share the public listener and need an admin token")
+	}
+	adminMux.HandleFunc("/api/debug/dbstats", app.handleDBStats)
+	adminMux.HandleFunc("/api/items/all", app.handleDeleteAll)
+	adminMux.HandleFunc(maintenancePath, app.handleMaintenance)
+	adminMux.Handle("/metrics", adminOnly(promhttp.Handler()))
+	if cfg.EnablePprof {
+		slog.Warn("ENABLE_PPROF is set; serving /debug/pprof/")
+		pprofMux := http.NewServeMux()
+		registerPprof(pprofMux)
+		adminMux.Handle("/debug/pprof/", adminOnly(pprofMux))
+	}
+
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		slog.Warn("CORS_ALLOWED_ORIGINS is empty; cross-origin requests will be denied")
+	}
+
+	app.hub = newWSHub(app, cfg.CORSAllowedOrigins)
+
+	var api http.Handler = mux
+	if cfg.JWTSecret != "" {
+		api = withAuth(api, []byte(cfg.JWTSecret))
+	} else {
+		slog.Warn("JWT_SECRET is empty; API authentication is disabled")
+	}
+	if len(cfg.APIKeys) > 0 {
+		// API_KEY_SCOPE=writes leaves GET endpoints public. With JWT auth
+		// on too, either credential will do: withAPIKey only marks valid
+		// keys and withAuth turns away requests with neither.
+		protect := allRequests
+		switch {
+		case cfg.JWTSecret != "":
+			protect = func(*http.Request) bool { return false }
+		case cfg.APIKeyScope == "writes":
+			protect = isWriteRequest
+		}
+		api = withAPIKey(api, cfg.APIKeys, protect)
+	}
+
+	app.maintenance.Store(cfg.MaintenanceMode)
+	if cfg.MaintenanceMode {
+		slog.Warn("MAINTENANCE_MODE is set; the API will return 503 until it is switched off")
+	}
+	api = app.withMaintenance(api)
+
+	if cfg.ReadOnly {
+		slog.Warn("READ_ONLY is set; write endpoints will return 503")
+		api = withReadOnly(api)
+	}
+	if cfg.RequestTimeout.Duration > 0 {
+		api = withTimeout(api, cfg.RequestTimeout.Duration)
+	}
+	api = withRoutePattern(api, mux)
 
-	handler := withCORS(mux)
+	// appCtx scopes background goroutines; it is cancelled on shutdown.
+	appCtx, stopApp := context.WithCancel(context.Background())
+	defer stopApp()
 
+	go app.events.run(appCtx, dsn)
+	go app.hub.run(appCtx)
+	go app.purgeIdempotencyKeys(appCtx)
+	go app.dbHealth.run(appCtx, db, cfg.DBHealthInterval.Duration)
+
+	handler := withGzip(withCORS(api, cfg.CORSAllowedOrigins, cfg.CORSAllowCredentials, cfg.CORSMaxAge.Duration))
+	if cfg.RateLimitPerMinute > 0 {
+		rl := newRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst)
+		go rl.cleanup(appCtx)
+		handler = withRateLimit(handler, rl)
+	}
+	if cfg.BasePath != "" {
+		slog.Info("serving the API under BASE_PATH", "base_path", cfg.BasePath)
+	}
+	// validateConfig already rejected malformed entries.
+	trustedProxies, _ := parseTrustedProxies(cfg.TrustedProxies)
+	notFound := newNotFoundGuard(cfg.NotFoundBlockThreshold, cfg.NotFoundWindow.Duration, cfg.NotFoundBlockDuration.Duration)
+	go notFound.cleanup(appCtx)
+	handler = withNotFoundGuard(withTrailingSlash(withBasePath(handler, cfg.BasePath), cfg.TrailingSlash, cfg.BasePath+"/api"), notFound)
+	handler = withRequestID(withClientIP(withRecover(withLogging(handler)), trustedProxies))
+
+	listenAddr := cfg.ListenAddr
+
+	// WriteTimeout bounds every response, including long-lived ones.
+	// Streaming handlers (SSE, export) opt out per request by clearing their
+	// deadline with http.ResponseController.SetWriteDeadline; WebSocket
+	// connections manage their own deadlines once hijacked.
 	srv := &http.Server{
-		Addr:         ":8080",
-		Handler:      handler,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              listenAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout.Duration,
+		ReadTimeout:       cfg.HTTPReadTimeout.Duration,
+		WriteTimeout:      cfg.HTTPWriteTimeout.Duration,
+		IdleTimeout:       cfg.HTTPIdleTimeout.Duration,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
 	}
 
-	log.Println("backend listening on :8080")
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("server error: %v", err)
+	certFile, keyFile := cfg.TLSCertFile, cfg.TLSKeyFile
+	useTLS := certFile != ""
+	if useTLS {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
 	}
-}
 
-func buildDSNFromEnv() string {
-	host := getEnvOrFile("DB_HOST", "localhost")
-	port := getEnvOrFile("DB_PORT", "5432")
-	user := getEnvOrFile("DB_USER", "app")
-	password := getEnvOrFile("DB_PASSWORD", "secret")
-	dbName := getEnvOrFile("DB_NAME", "appdb")
-	sslmode := getEnvOrFile("DB_SSLMODE", "disable")
+	serverErr := make(chan error, 2)
 
-	return fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		url.QueryEscape(user),
-		url.QueryEscape(password),
-		host,
-		port,
-		dbName,
-		sslmode,
-	)
-}
+	// The admin listener sits on an internal port, so it skips the public
+	// middleware chain and has no WriteTimeout, letting long pprof profiles
+	// finish.
+	var adminSrv *http.Server
+	if cfg.AdminListenAddr != "" {
+		adminSrv = &http.Server{
+			Addr:              cfg.AdminListenAddr,
+			Handler:           withRecover(withAdminListener(adminMux)),
+			ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout.Duration,
+			IdleTimeout:       cfg.HTTPIdleTimeout.Duration,
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		}
+		go func() {
+			slog.Info("admin listening", "addr", cfg.AdminListenAddr, "scheme", "http")
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serverErr <- fmt.Errorf("admin: %w", err)
+			}
+		}()
+	}
+
+	go func() {
+		var err error
+		if useTLS {
+			slog.Info("backend listening", "addr", listenAddr, "scheme", "https")
+			err = srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			slog.Info("backend listening", "addr", listenAddr, "scheme", "http")
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
 
-func getEnvOrFile(key, def string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
+		fatal("server error", "err", err)
+	case sig := <-stop:
+		slog.Info("shutting down", "signal", sig.String())
+	}
+
+	// Swarm sends SIGTERM on rolling updates; let in-flight requests drain
+	// before the DB pool goes away.
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
+
+	// Shutdown waits for SSE handlers and never sees hijacked WebSocket
+	// connections, so both are told to close first.
+	app.drain()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server shutdown error", "err", err)
+	}
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("admin server shutdown error", "err", err)
+		}
+	}
+	stopApp()
+	app.stmts.close()
+	if err := db.Close(); err != nil {
+		slog.Error("failed to close DB", "err", err)
 	}
 
-	if filePath := os.Getenv(key + "_FILE"); filePath != "" {
-		data, err := os.ReadFile(filePath)
+	slog.Info("shutdown complete")
+}
+
+// waitForDB pings until the database answers or timeout elapses, backing off
+// exponentially between attempts. On a fresh swarm deploy Postgres is often
+// still starting when the backend comes up.
+func waitForDB(db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := 500 * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := db.PingContext(ctx)
+		cancel()
 		if err == nil {
-			s := strings.TrimSpace(string(data))
-			if s != "" {
-				return s
-			}
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
 		}
+
+		wait := min(backoff, remaining)
+		slog.Warn("DB not ready; retrying", "attempt", attempt, "err", err, "wait", wait)
+		time.Sleep(wait)
+		backoff = min(backoff*2, 10*time.Second)
+	}
+}
+
+// validateListenAddr checks addr is host:port with a numeric port, e.g.
+// ":8080" or "10.0.0.5:8080".
+func validateListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("port %q is not a number between 0 and 65535", port)
+	}
+	return nil
+}
+
+const errDuplicateTitle = "an item with this title already exists"
+
+// isUniqueViolation reports whether err is Postgres SQLSTATE 23505. The code
+// is checked rather than the message, which is localized.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation
+}
+
+// withQueryTimeout bounds r's context by queryTimeout so a slow query can't
+// hold its connection until the client gives up. Streaming endpoints don't
+// use it.
+func (a *App) withQueryTimeout(r *http.Request) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(r.Context(), a.queryTimeout)
+	return r.WithContext(ctx), cancel
+}
+
+// writeDBError answers a failed DB call: 504 when the query ran out of time,
+// otherwise a 500 carrying msg.
+func writeDBError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeJSONError(w, http.StatusGatewayTimeout, "database query timed out")
+		return
 	}
+	writeJSONError(w, http.StatusInternalServerError, msg)
+}
 
-	return def
+// clientGone reports whether r failed because the client disconnected, as
+// opposed to the query timing out or the DB failing. There is no one left to
+// answer and nothing worth logging.
+func clientGone(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
 }
 
-func migrate(db *sql.DB) error {
-	const q = `
-CREATE TABLE IF NOT EXISTS items (
-    id SERIAL PRIMARY KEY,
-    title TEXT NOT NULL,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
-);`
-	_, err := db.Exec(q)
-	return err
+// inTx runs fn in a transaction tied to ctx, so a client disconnect cancels
+// it. The transaction commits only if fn returns nil; otherwise it is rolled
+// back and fn's error is returned unchanged. A transaction that loses a
+// serialization conflict or deadlock is rerun from scratch, so fn must reset
+// any state it accumulates outside itself.
+func (a *App) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return a.retry(ctx, "transaction", isSerializationFailure, func() error {
+		return a.runTx(ctx, fn)
+	})
 }
 
+func (a *App) runTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// handleHealth predates the live/ready split and keeps readiness semantics
+// for existing callers such as the Dockerfile HEALTHCHECK.
 func (a *App) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
-	defer cancel()
+	a.handleReady(w, r)
+}
 
-	if err := a.db.PingContext(ctx); err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		_ = json.NewEncoder(w).Encode(map[string]string{"status": "down"})
+// handleLive reports that the process is up. It never touches the DB, so a
+// database blip does not get the container killed.
+func (a *App) handleLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// handleReady reports whether the instance can serve traffic, going by the
+// last background DB check rather than querying per probe. The check also
+// fails while the schema is behind this binary's migrations. HEAD runs the same
+// check; net/http drops the body.
+func (a *App) handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	status := a.dbHealth.status()
+	if status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
+
 func (a *App) handleItems(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
-	case http.MethodGet:
+	case http.MethodGet, http.MethodHead:
+		if r.URL.Query().Has("ids") {
+			a.getItemsByIDs(w, r)
+			return
+		}
 		a.listItems(w, r)
 	case http.MethodPost:
 		a.createItem(w, r)
+	case http.MethodDelete:
+		a.deleteItemsBulk(w, r)
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, DELETE, OPTIONS")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (a *App) handleItem(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		a.getItem(w, r)
+	case http.MethodPut:
+		a.updateItem(w, r)
+	case http.MethodPatch:
+		a.patchItem(w, r)
+	case http.MethodDelete:
+		a.deleteItem(w, r)
 	case http.MethodOptions:
 		w.WriteHeader(http.StatusNoContent)
 	default:
-		w.Header().Set("Allow", "GET, POST, OPTIONS")
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		w.Header().Set("Allow", "GET, HEAD, PUT, PATCH, DELETE, OPTIONS")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// unknownFieldPrefix starts the error DisallowUnknownFields produces.
+// encoding/json has no typed error for this case, so the wording is pinned
+// by a test instead.
+const unknownFieldPrefix = "json: unknown field "
+
+// decodeJSON reads a size-capped JSON body into dst, rejecting fields dst
+// doesn't declare so typos like "titel" fail loudly. On failure it writes the
+// error response itself and returns false.
+func (a *App) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, a.maxBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return false
+		}
+		// Decode reports an empty or all-whitespace body as a bare io.EOF.
+		if errors.Is(err, io.EOF) {
+			writeJSONError(w, http.StatusBadRequest, "request body is empty")
+			return false
+		}
+		if field, ok := strings.CutPrefix(err.Error(), unknownFieldPrefix); ok {
+			writeValidationErrors(w, ValidationErrors{{Field: strings.Trim(field, `"`), Message: "unexpected field"}})
+			return false
+		}
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON")
+		return false
+	}
+	return true
+}
+
+// isJSONContentType accepts application/json with optional parameters such
+// as charset.
+func isJSONContentType(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	return err == nil && mediaType == "application/json"
+}
+
+// parseItemID extracts the {id} path value registered on the item routes.
+// IDs come from a SERIAL column, so anything below 1 is rejected up front.
+func parseItemID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if id <= 0 {
+		return 0, fmt.Errorf("item id must be positive, got %d", id)
+	}
+	return id, nil
+}
+
+func (a *App) getItem(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	id, err := parseItemID(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var item Item
+	err = a.retryRead(r.Context(), "get item", func() error {
+		return scanItem(a.queryRow(
+			r.Context(),
+			a.stmts.getItem,
+			getItemSQL,
+			id,
+			includeDeleted(r),
+			ownerFilter(r),
+		), &item)
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusNotFound, "item not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get item", "item_id", id, "err", err)
+		writeDBError(w, err, "failed to load item")
+		return
+	}
+
+	// Each representation needs its own strong validator.
+	asJSONAPI := wantsJSONAPI(r)
+	asXML := !asJSONAPI && wantsXML(r)
+	etag := itemETag(item)
+	switch {
+	case asJSONAPI:
+		etag = strings.TrimSuffix(etag, `"`) + `-jsonapi"`
+	case asXML:
+		etag = strings.TrimSuffix(etag, `"`) + `-xml"`
+	}
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	switch {
+	case asJSONAPI:
+		a.writeJSONAPIItem(w, item)
+		return
+	case asXML:
+		writeXML(w, item)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(item)
+}
+
+// itemETag derives a strong validator from the fields that change whenever
+// the item does.
+func itemETag(it Item) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d|%d", it.ID, it.UpdatedAt.UnixNano())
+	if it.DeletedAt != nil {
+		fmt.Fprintf(h, "|%d", it.DeletedAt.UnixNano())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches implements the If-None-Match comparison, which may carry a
+// list of tags or "*".
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) updateItem(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	defer r.Body.Close()
+
+	id, err := parseItemID(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req updateItemRequest
+	if !a.decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs ValidationErrors
+	v := a.validateItem(&errs, "", itemInput{Title: &req.Title, Description: &req.Description}, false)
+	checkVersion(&errs, req.Version)
+	if !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	var item Item
+	err = a.inTx(r.Context(), func(tx *sql.Tx) error {
+		if err := scanItem(tx.QueryRowContext(
+			r.Context(),
+			`UPDATE items SET title = $1, description = NULLIF($2, ''), updated_at = now(), version = version + 1
+			 WHERE id = $3 AND version = $5 AND deleted_at IS NULL AND `+ownerMatches("$4")+` RETURNING `+itemColumns,
+			*v.Title,
+			*v.Description,
+			id,
+			ownerFilter(r),
+			*req.Version,
+		), &item); err != nil {
+			return err
+		}
+		if err := recordAudit(r.Context(), tx, item.ID, auditUpdate, item); err != nil {
+			return err
+		}
+		return notifyItemEvent(r.Context(), tx, auditUpdate, item.ID)
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		a.writeUpdateMiss(w, r, id)
+		return
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeJSONError(w, http.StatusConflict, errDuplicateTitle)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to update item", "item_id", id, "err", err)
+		writeDBError(w, err, "failed to update item")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(item)
+}
+
+func (a *App) deleteItem(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	id, err := parseItemID(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	err = a.inTx(r.Context(), func(tx *sql.Tx) error {
+		// Items are archived rather than removed; see listItems ?include_deleted.
+		var item Item
+		if err := scanItem(tx.QueryRowContext(
+			r.Context(),
+			`UPDATE items SET deleted_at = now(), version = version + 1
+			 WHERE id = $1 AND deleted_at IS NULL AND `+ownerMatches("$2")+` RETURNING `+itemColumns,
+			id,
+			ownerFilter(r),
+		), &item); err != nil {
+			return err
+		}
+		if err := recordAudit(r.Context(), tx, item.ID, auditDelete, item); err != nil {
+			return err
+		}
+		return notifyItemEvent(r.Context(), tx, auditDelete, item.ID)
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusNotFound, "item not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to delete item", "item_id", id, "err", err)
+		writeDBError(w, err, "failed to delete item")
+		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// insertItem creates one item with its tags and audit entry inside tx. The
+// fields must already have passed validateItem.
+func (a *App) insertItem(ctx context.Context, tx *sql.Tx, owner sql.NullString, title, description string, tags []string, createdAt sql.NullTime) (Item, error) {
+	var item Item
+	if err := scanItem(queryRowTx(
+		ctx,
+		tx,
+		a.stmts.insertItem,
+		insertItemSQL,
+		title,
+		strings.TrimSpace(description),
+		owner,
+		createdAt,
+	), &item); err != nil {
+		return Item{}, err
+	}
+
+	item.Tags = normalizeTags(tags)
+	if err := addItemTags(ctx, tx, item.ID, item.Tags); err != nil {
+		return Item{}, err
+	}
+	if err := recordAudit(ctx, tx, item.ID, auditCreate, item); err != nil {
+		return Item{}, err
+	}
+	if err := notifyItemEvent(ctx, tx, auditCreate, item.ID); err != nil {
+		return Item{}, err
+	}
+	return item, nil
 }
 
 func (a *App) createItem(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
 	defer r.Body.Close()
 
 	var req createItemRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
+	if !a.decodeJSON(w, r, &req) {
 		return
 	}
 
-	req.Title = strings.TrimSpace(req.Title)
-	if req.Title == "" {
-		http.Error(w, "title is required", http.StatusBadRequest)
+	var errs ValidationErrors
+	v := a.validateItem(&errs, "", itemInput{Title: &req.Title, Description: &req.Description, Tags: req.Tags}, false)
+	createdAt := parseCreatedAt(&errs, "created_at", req.CreatedAt)
+	if !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+	if createdAt.Valid && !requireAdmin(w, r) {
 		return
 	}
 
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(key) > maxIdempotencyKeyLen {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key exceeds %d characters", maxIdempotencyKeyLen))
+		return
+	}
+
+	owner := requestOwner(r)
+	checkDuplicates := queryBool(r, "check_duplicates") && !queryBool(r, "force")
 	var item Item
-	err := a.db.QueryRowContext(
-		r.Context(),
-		`INSERT INTO items (title) VALUES ($1) RETURNING id, title, created_at`,
-		req.Title,
-	).Scan(&item.ID, &item.Title, &item.CreatedAt)
+	var duplicateID int64
+	replayed := false
+	err := a.inTx(r.Context(), func(tx *sql.Tx) error {
+		var err error
+		if key != "" {
+			item, replayed, err = a.lookupIdempotencyKey(r.Context(), tx, owner, key)
+			if err != nil || replayed {
+				return err
+			}
+		}
+		if checkDuplicates {
+			duplicateID, err = findSimilarTitle(r.Context(), tx, owner, *v.Title)
+			if err != nil {
+				return err
+			}
+			if duplicateID != 0 {
+				return errPossibleDuplicate
+			}
+		}
+		item, err = a.insertItem(r.Context(), tx, owner, *v.Title, *v.Description, v.Tags, createdAt)
+		if err != nil {
+			return err
+		}
+		if key != "" {
+			return saveIdempotencyKey(r.Context(), tx, owner, key, item.ID)
+		}
+		return nil
+	})
 
 	if err != nil {
-		log.Printf("failed to insert item: %v", err)
-		http.Error(w, "failed to create item", http.StatusInternalServerError)
+		if errors.Is(err, errPossibleDuplicate) {
+			writeDuplicateWarning(w, duplicateID)
+			return
+		}
+		if isUniqueViolation(err) {
+			writeJSONError(w, http.StatusConflict, errDuplicateTitle)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to insert item", "err", err)
+		writeDBError(w, err, "failed to create item")
 		return
 	}
 
+	// A replayed key answers with the original item and 200 rather than 201,
+	// so clients can tell nothing new was created.
+	status := http.StatusCreated
+	if replayed {
+		status = http.StatusOK
+	}
+	w.Header().Set("Location", a.itemLocation(item.ID))
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(item)
 }
 
-func (a *App) listItems(w http.ResponseWriter, r *http.Request) {
-	rows, err := a.db.QueryContext(
+// checkVersion requires the version an update was based on.
+func checkVersion(errs *ValidationErrors, version *int64) {
+	if version == nil {
+		errs.Add("version", "version is required")
+	}
+}
+
+// writeUpdateMiss answers an UPDATE guarded by version that matched no row:
+// 409 if the item is still there, so the version must have moved on,
+// otherwise 404.
+func (a *App) writeUpdateMiss(w http.ResponseWriter, r *http.Request, id int64) {
+	var exists bool
+	err := a.db.QueryRowContext(
 		r.Context(),
-		`SELECT id, title, created_at FROM items ORDER BY created_at DESC`,
-	)
+		`SELECT EXISTS (SELECT 1 FROM items WHERE id = $1 AND deleted_at IS NULL AND `+ownerMatches("$2")+`)`,
+		id,
+		ownerFilter(r),
+	).Scan(&exists)
+	switch {
+	case err != nil:
+		slog.ErrorContext(r.Context(), "failed to check item", "item_id", id, "err", err)
+		writeDBError(w, err, "failed to update item")
+	case exists:
+		writeJSONError(w, http.StatusConflict, "item was modified by another request; reload and retry")
+	default:
+		writeJSONError(w, http.StatusNotFound, "item not found")
+	}
+}
+
+// itemLocation is the URL of the single-item endpoint for id, including
+// BASE_PATH.
+func (a *App) itemLocation(id int64) string {
+	return a.basePath + "/api/items/" + strconv.FormatInt(id, 10)
+}
+
+// parsePagination reads ?limit= and ?offset=. Bad values are clamped to the
+// defaults instead of rejected so sloppy clients still get a page back.
+func (a *App) parsePagination(r *http.Request) (limit, offset int) {
+	q := r.URL.Query()
+
+	limit = a.pageSize
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		limit = min(v, a.maxPageSize)
+	}
+
+	if v, err := strconv.Atoi(q.Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	return limit, offset
+}
+
+// itemQuery accumulates WHERE conditions and their bind args so the list and
+// count queries always filter exactly the same rows.
+type itemQuery struct {
+	conds []string
+	args  []any
+}
+
+// arg binds v and returns its positional placeholder.
+func (q *itemQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *itemQuery) where(cond string) {
+	q.conds = append(q.conds, cond)
+}
+
+func (q *itemQuery) whereSQL() string {
+	if len(q.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conds, " AND ")
+}
+
+// parseTimeBound parses ?from= or ?to= as an RFC3339 timestamp or a bare
+// YYYY-MM-DD date (midnight UTC). A bare date given as the exclusive upper
+// bound is moved to the following midnight so the day itself is included.
+func parseTimeBound(r *http.Request, key string, upper bool) (time.Time, bool, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get(key))
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s must be an RFC3339 timestamp or a YYYY-MM-DD date", key)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true, nil
+}
+
+// itemFilters translates the list query string into WHERE conditions. User
+// input only ever reaches the SQL as bound parameters.
+func itemFilters(r *http.Request) (*itemQuery, error) {
+	q := &itemQuery{}
+
+	if !includeDeleted(r) {
+		q.where(`deleted_at IS NULL`)
+	}
+	if owner := ownerFilter(r); owner.Valid {
+		q.where(`owner_id = ` + q.arg(owner.String))
+	}
+	if term := strings.TrimSpace(r.URL.Query().Get("q")); term != "" {
+		q.where(`title ILIKE '%' || ` + q.arg(term) + ` || '%'`)
+	}
+	if tag := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag"))); tag != "" {
+		q.where(`EXISTS (SELECT 1 FROM item_tags it JOIN tags t ON t.id = it.tag_id
+			WHERE it.item_id = items.id AND t.name = ` + q.arg(tag) + `)`)
+	}
+
+	from, hasFrom, err := parseTimeBound(r, "from", false)
+	if err != nil {
+		return nil, err
+	}
+	to, hasTo, err := parseTimeBound(r, "to", true)
 	if err != nil {
-		log.Printf("failed to query items: %v", err)
-		http.Error(w, "failed to load items", http.StatusInternalServerError)
+		return nil, err
+	}
+	if hasFrom {
+		q.where(`created_at >= ` + q.arg(from))
+	}
+	if hasTo {
+		q.where(`created_at < ` + q.arg(to))
+	}
+
+	return q, nil
+}
+
+// queryBool reports whether query parameter key is set to a true value.
+func queryBool(r *http.Request, key string) bool {
+	v, err := strconv.ParseBool(r.URL.Query().Get(key))
+	return err == nil && v
+}
+
+// sortColumns whitelists the columns clients may sort by. Column names can't
+// be bound as parameters, so only values from this map reach the SQL.
+var sortColumns = map[string]string{
+	"created_at": "created_at",
+	"title":      "title",
+	"id":         "id",
+}
+
+// parseSort builds the ORDER BY clause from ?sort= and ?order=. id is added
+// as a tie-breaker so pages stay stable when the sort column has duplicates.
+func parseSort(r *http.Request) (string, error) {
+	q := r.URL.Query()
+
+	sort := q.Get("sort")
+	if sort == "" {
+		sort = "created_at"
+	}
+	col, ok := sortColumns[sort]
+	if !ok {
+		return "", fmt.Errorf("invalid sort field %q", sort)
+	}
+
+	var dir string
+	switch strings.ToLower(q.Get("order")) {
+	case "", "desc":
+		dir = "DESC"
+	case "asc":
+		dir = "ASC"
+	default:
+		return "", fmt.Errorf("invalid sort order %q", q.Get("order"))
+	}
+
+	if col == "id" {
+		return " ORDER BY id " + dir, nil
+	}
+	return " ORDER BY " + col + " " + dir + ", id " + dir, nil
+}
+
+// listItems pages with ?limit=/?offset= by default. Passing ?cursor= (empty
+// for the first page) switches to keyset pagination over (created_at, id),
+// which stays stable under concurrent inserts, and wraps the response in an
+// itemPage carrying next_cursor. ?envelope=true returns a listEnvelope in
+// either mode instead; it will become the default in a future version.
+func (a *App) listItems(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	limit, offset := a.parsePagination(r)
+	q, err := itemFilters(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	defer rows.Close()
 
-	items := make([]Item, 0, 16)
-	for rows.Next() {
-		var it Item
-		if err := rows.Scan(&it.ID, &it.Title, &it.CreatedAt); err != nil {
-			log.Printf("failed to scan item: %v", err)
-			http.Error(w, "failed to load items", http.StatusInternalServerError)
+	orderBy, err := parseSort(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	params := r.URL.Query()
+	cursorMode := params.Has("cursor")
+	w.Header().Add("Vary", "Accept")
+	ndjson := accepts(r, ndjsonContentType)
+	if ndjson && cursorMode {
+		writeJSONError(w, http.StatusBadRequest, "ndjson responses don't support cursor pagination")
+		return
+	}
+	var cursor *itemCursor
+	if cursorMode {
+		if params.Get("sort") != "" || params.Get("order") != "" {
+			writeJSONError(w, http.StatusBadRequest, "cursor pagination only supports the default sort")
 			return
 		}
-		items = append(items, it)
+		if raw := params.Get("cursor"); raw != "" {
+			c, err := decodeCursor(raw)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			cursor = &c
+		}
+		offset = 0
+	}
+
+	var lastModified time.Time
+	err = a.retryRead(r.Context(), "check items last modified", func() error {
+		var err error
+		lastModified, err = a.itemsLastModified(r.Context(), ownerFilter(r))
+		return err
+	})
+	if err != nil {
+		if clientGone(r) {
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to check items last modified", "err", err)
+		writeDBError(w, err, "failed to load items")
+		return
+	}
+	if a.writeListCaching(w, r, lastModified) {
+		return
+	}
+
+	var total int64
+	err = a.retryRead(r.Context(), "count items", func() error {
+		var err error
+		total, err = a.countMatching(r.Context(), q)
+		return err
+	})
+	if err != nil {
+		if clientGone(r) {
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to count items", "err", err)
+		writeDBError(w, err, "failed to load items")
+		return
+	}
+
+	// The cursor narrows the page, not the result set, so it is added only
+	// after the total has been counted.
+	if cursor != nil {
+		q.where(`(created_at, id) < (` + q.arg(cursor.CreatedAt) + `, ` + q.arg(cursor.ID) + `)`)
 	}
-	if err := rows.Err(); err != nil {
-		log.Printf("rows error: %v", err)
-		http.Error(w, "failed to load items", http.StatusInternalServerError)
+
+	query := `SELECT ` + itemColumns + ` FROM items` + q.whereSQL() + orderBy +
+		` LIMIT ` + q.arg(limit) + ` OFFSET ` + q.arg(offset)
+
+	if ndjson {
+		a.streamItemsNDJSON(w, r, query, q.args, limit, offset, total)
+		return
+	}
+
+	var items []Item
+	err = a.retryRead(r.Context(), "list items", func() error {
+		var err error
+		items, err = a.queryItems(r.Context(), query, q.args...)
+		return err
+	})
+	if err != nil {
+		if clientGone(r) {
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to query items", "err", err)
+		writeDBError(w, err, "failed to load items")
+		return
+	}
+
+	var nextCursor string
+	if cursorMode && len(items) == limit {
+		nextCursor = encodeCursor(items[len(items)-1])
+	}
+
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	links := a.setPageLinks(w, r, limit, offset, len(items), total, nextCursor, cursorMode)
+	switch {
+	case wantsJSONAPI(r):
+		a.writeJSONAPIList(w, items, total, links)
+		return
+	case wantsXML(r):
+		writeXML(w, itemsXML{Items: items})
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(items)
+	switch {
+	case queryBool(r, "envelope"):
+		_ = json.NewEncoder(w).Encode(listEnvelope{
+			Data: items,
+			Page: pageInfo{Limit: limit, Offset: offset, Total: total, NextCursor: nextCursor},
+		})
+	case cursorMode:
+		_ = json.NewEncoder(w).Encode(itemPage{Items: items, NextCursor: nextCursor})
+	default:
+		_ = json.NewEncoder(w).Encode(items)
+	}
 }
 
-func withCORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// For learning: allow everything.
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
+// streamItemsNDJSON writes the list page as one JSON object per line,
+// flushing after each row so clients can start on the first item while the
+// rest are still being read from the DB.
+func (a *App) streamItemsNDJSON(w http.ResponseWriter, r *http.Request, query string, args []any, limit, offset int, total int64) {
+	rows, err := a.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		if clientGone(r) {
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to query items", "err", err)
+		writeDBError(w, err, "failed to load items")
+		return
+	}
+	defer rows.Close()
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	pageLen := int(min(int64(limit), max(total-int64(offset), 0)))
+	a.setPageLinks(w, r, limit, offset, pageLen, total, "", false)
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for rows.Next() {
+		if clientGone(r) {
+			return
+		}
+		var it Item
+		if err := scanItem(rows, &it); err != nil {
+			// The status line is gone; all we can do is stop.
+			slog.ErrorContext(r.Context(), "failed to scan item", "err", err)
+			return
+		}
+		if err := enc.Encode(it); err != nil {
 			return
 		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return
+		}
+	}
+	if err := rows.Err(); err != nil && !clientGone(r) {
+		slog.ErrorContext(r.Context(), "rows error while streaming items", "err", err)
+	}
+}
+
+// queryItems runs query, which must select itemColumns, and collects every
+// row.
+func (a *App) queryItems(ctx context.Context, query string, args ...any) ([]Item, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		next.ServeHTTP(w, r)
+	items := make([]Item, 0, 16)
+	for rows.Next() {
+		// Stop reading a page nobody is waiting for.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var it Item
+		if err := scanItem(rows, &it); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// countMatching counts the rows q selects without reading any item data.
+func (a *App) countMatching(ctx context.Context, q *itemQuery) (int64, error) {
+	var n int64
+	err := a.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM items`+q.whereSQL(), q.args...).Scan(&n)
+	return n, err
+}
+
+// countItems returns how many items a list request with the same filters
+// would match in total.
+func (a *App) countItems(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	q, err := itemFilters(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var n int64
+	err = a.retryRead(r.Context(), "count items", func() error {
+		var err error
+		n, err = a.countMatching(r.Context(), q)
+		return err
 	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to count items", "err", err)
+		writeDBError(w, err, "failed to count items")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int64{"count": n})
 }
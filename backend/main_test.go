@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingConnector fails every connection attempt and counts them, so a
+// test can assert a handler never reached the database.
+type countingConnector struct{ attempts atomic.Int32 }
+
+func (c *countingConnector) Connect(context.Context) (driver.Conn, error) {
+	c.attempts.Add(1)
+	return nil, errors.New("no database in tests")
+}
+
+func (c *countingConnector) Driver() driver.Driver { return nil }
+
+func TestCreateItemRejectsUnknownField(t *testing.T) {
+	conn := &countingConnector{}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	app := &App{db: db, maxTitleLen: defaultMaxTitleLen, maxBodyBytes: defaultMaxBodyBytes, queryTimeout: time.Second}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/items", strings.NewReader(`{"titel":"typo"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.handleItems(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if len(body.Errors) != 1 || body.Errors[0].Field != "titel" {
+		t.Errorf("errors = %+v, want one for field titel", body.Errors)
+	}
+	if n := conn.attempts.Load(); n != 0 {
+		t.Errorf("handler touched the database %d times, want none", n)
+	}
+}
+
+// decodeJSON maps this error to a field by string matching, which would
+// silently stop working if encoding/json reworded it.
+func TestUnknownFieldErrorWording(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"titel":"typo"}`))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&createItemRequest{})
+	if err == nil {
+		t.Fatal("Decode accepted an unknown field")
+	}
+	field, ok := strings.CutPrefix(err.Error(), unknownFieldPrefix)
+	if !ok {
+		t.Fatalf("error %q does not start with %q", err, unknownFieldPrefix)
+	}
+	if field != `"titel"` {
+		t.Errorf("field = %s, want \"titel\"", field)
+	}
+}
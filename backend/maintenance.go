@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	maintenancePath = "/api/admin/maintenance"
+
+	// maintenanceRetryAfter is the Retry-After hint, in seconds, sent while
+	// maintenance mode is on.
+	maintenanceRetryAfter = 120
+)
+
+// withMaintenance answers 503 for everything except the probes and the
+// toggle itself while a.maintenance is set. The flag is read per request,
+// so flipping it takes effect immediately.
+func (a *App) withMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.maintenance.Load() && !publicPaths[r.URL.Path] && r.URL.Path != maintenancePath {
+			w.Header().Set("Retry-After", strconv.Itoa(maintenanceRetryAfter))
+			writeJSONError(w, http.StatusServiceUnavailable, "service under maintenance")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type maintenanceState struct {
+	Enabled bool `json:"enabled"`
+}
+
+// handleMaintenance reports the maintenance flag on GET and sets it on PUT
+// with {"enabled": true|false}. Both go through requireAdmin, so with JWT
+// auth disabled the toggle is only reachable on the admin listener.
+func (a *App) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req maintenanceState
+		if !a.decodeJSON(w, r, &req) {
+			return
+		}
+		a.maintenance.Store(req.Enabled)
+		slog.InfoContext(r.Context(), "maintenance mode changed", "enabled", req.Enabled)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(maintenanceState{Enabled: a.maintenance.Load()})
+}
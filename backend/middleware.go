@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// responseWriter records the status code and body size so middleware can
+// report on a response after the handler has written it.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.status = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Hijack supports WebSocket upgrades, whose libraries type-assert for
+// http.Hijacker rather than using http.ResponseController.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// routeInfo carries the matched mux pattern back up to withLogging.
+// Middleware in between replaces the *http.Request via WithContext, so
+// reading r.Pattern in withLogging would see a copy the mux never touched.
+type routeInfo struct {
+	pattern string
+}
+
+type routeInfoKey struct{}
+
+// withRoutePattern records the pattern mux will match before calling next.
+// It must sit outside withTimeout: TimeoutHandler runs next on another
+// goroutine and may return while it is still running, so a pattern written
+// after the mux returns would race with withLogging reading it.
+func withRoutePattern(next http.Handler, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(routeInfoKey{}).(*routeInfo); ok {
+			_, info.pattern = mux.Handler(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withLogging logs one line per request and records the HTTP metrics.
+func withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := newResponseWriter(w)
+
+		info := &routeInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), routeInfoKey{}, info))
+
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
+		next.ServeHTTP(rw, r)
+
+		elapsed := time.Since(start)
+		route := routeLabel(info.pattern)
+		httpRequestsTotal.WithLabelValues(r.Method, route, strconv.Itoa(rw.status)).Inc()
+		httpRequestDuration.WithLabelValues(r.Method, route).Observe(elapsed.Seconds())
+
+		slog.InfoContext(
+			r.Context(),
+			"request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"client_ip", clientIP(r),
+			"status", rw.status,
+			"bytes", rw.bytes,
+			"duration", elapsed,
+		)
+	})
+}
+
+// withRecover turns a handler panic into a 500 instead of letting it take the
+// connection down. Only withRequestID and withClientIP may wrap it, so that
+// the panic log carries their fields; both must stay panic-free.
+func withRecover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				// Deliberate abort; let net/http handle it quietly.
+				panic(rec)
+			}
+
+			slog.ErrorContext(
+				r.Context(),
+				"panic serving request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", fmt.Sprint(rec),
+				"stack", string(debug.Stack()),
+			)
+
+			writeJSONError(w, http.StatusInternalServerError, "internal server error")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// streamingPaths hold their connection open by design and are exempt from
+// withTimeout, which would also buffer them and break flushing and hijacking.
+var streamingPaths = map[string]bool{
+	"/api/items/stream": true,
+	"/api/items/export": true,
+	"/api/ws":           true,
+}
+
+// withTimeout bounds the total time of every non-streaming request, as a
+// backstop for handlers that don't bound their own work. Past d the client
+// gets a JSON 503 and the handler's context is cancelled.
+func withTimeout(next http.Handler, d time.Duration) http.Handler {
+	th := http.TimeoutHandler(next, d, `{"error":"request timed out"}`+"\n")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if streamingPaths[r.URL.Path] || accepts(r, ndjsonContentType) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		th.ServeHTTP(timeoutResponseWriter{w}, r)
+	})
+}
+
+// timeoutResponseWriter labels TimeoutHandler's bare 503 body as JSON.
+// Handlers' own 503s already set a Content-Type and are left alone.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w timeoutResponseWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w timeoutResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withReadOnly rejects every state-changing request, for maintenance windows
+// or when pointed at a read replica. Reads pass through untouched, as does
+// the maintenance toggle, which changes no data.
+func withReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWriteRequest(r) && r.URL.Path != maintenancePath {
+			writeJSONError(w, http.StatusServiceUnavailable, "service in read-only mode")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+const (
+	trailingSlashStrip   = "strip"
+	trailingSlashRequire = "require"
+	trailingSlashOff     = "off"
+)
+
+// withTrailingSlash makes one spelling of each path canonical and answers
+// the other with a 308, which keeps the method and body. Under strip,
+// "/api/items/" redirects to "/api/items"; under require it's the reverse,
+// and the slash is trimmed again before routing so the mux patterns stay
+// slash-free. Only paths under apiPrefix are touched: the subtree patterns
+// outside it, like /debug/pprof/, need their slash, and ServeMux would
+// redirect straight back. Paths starting with "//" are left alone too,
+// since a browser would read their redirect as another host.
+func withTrailingSlash(next http.Handler, policy, apiPrefix string) http.Handler {
+	if policy == trailingSlashOff {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		if !strings.HasPrefix(p, apiPrefix+"/") || strings.HasPrefix(p, "//") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		hasSlash := strings.HasSuffix(p, "/")
+		switch {
+		case policy == trailingSlashStrip && hasSlash:
+			redirectPath(w, r, strings.TrimRight(r.URL.EscapedPath(), "/"))
+		case policy == trailingSlashRequire && !hasSlash:
+			redirectPath(w, r, r.URL.EscapedPath()+"/")
+		case policy == trailingSlashRequire:
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = strings.TrimSuffix(p, "/")
+			r2.URL.RawPath = strings.TrimSuffix(r.URL.RawPath, "/")
+			next.ServeHTTP(w, r2)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// redirectPath answers with a 308 to escapedPath, keeping the query.
+func redirectPath(w http.ResponseWriter, r *http.Request, escapedPath string) {
+	if escapedPath == "" {
+		escapedPath = "/"
+	}
+	if r.URL.RawQuery != "" {
+		escapedPath += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, escapedPath, http.StatusPermanentRedirect)
+}
+
+// withBasePath serves next under base, e.g. "/backend", stripping it before
+// routing so handlers and middleware further in keep seeing "/api/...".
+// Paths outside base get a 404.
+func withBasePath(next http.Handler, base string) http.Handler {
+	if base == "" {
+		return next
+	}
+	strip := http.StripPrefix(base, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, base)
+		if !ok || !strings.HasPrefix(rest, "/") {
+			writeJSONError(w, http.StatusNotFound, "not found")
+			return
+		}
+		strip.ServeHTTP(w, r)
+	})
+}
+
+// corsAllowedHeaders are the request headers the API reads that a browser
+// would otherwise refuse to send cross-origin without a preflight allowing
+// them.
+var corsAllowedHeaders = strings.Join([]string{
+	"Content-Type", "Authorization", "X-API-Key", "Idempotency-Key",
+	"If-None-Match", "If-Modified-Since", confirmDeleteAllHeader, requestIDHeader,
+}, ", ")
+
+// corsExposedHeaders are the non-safelisted response headers the API sets
+// that browser code needs to read, e.g. X-Total-Count for pagination.
+var corsExposedHeaders = strings.Join([]string{
+	"X-Total-Count", "Link", "ETag", "Last-Modified", "Location", "Retry-After", requestIDHeader,
+}, ", ")
+
+// withCORS echoes the request Origin back only when it is on the allowlist.
+// An empty allowlist denies every cross-origin request. Browsers may cache
+// a preflight for maxAge; 0 leaves it to their default.
+func withCORS(next http.Handler, allowedOrigins []string, allowCredentials bool, maxAge time.Duration) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		allowed[o] = true
+	}
+	maxAgeSecs := strconv.Itoa(int(maxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		if origin := r.Header.Get("Origin"); origin != "" && allowed[origin] {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
+			w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			if maxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", maxAgeSecs)
+			}
+			if allowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
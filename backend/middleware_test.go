@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithRecoverReturnsJSON500(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/boom", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+	// Wrapped the way main wires it, so the request id and client IP
+	// middleware run around the recovery.
+	h := withRequestID(withClientIP(withRecover(withLogging(mux)), nil))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/boom", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	if rec.Header().Get(requestIDHeader) == "" {
+		t.Errorf("missing %s header", requestIDHeader)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Error != "internal server error" {
+		t.Errorf("error = %q, want %q", body.Error, "internal server error")
+	}
+}
+
+func TestWithRecoverRepanicsOnAbort(t *testing.T) {
+	h := withRecover(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", rec)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestWithTrailingSlash(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/items", func(w http.ResponseWriter, r *http.Request) {})
+	registerPprof(mux)
+
+	tests := []struct {
+		policy, path string
+		wantStatus   int
+		wantLocation string
+	}{
+		{trailingSlashStrip, "/api/items/?limit=5", http.StatusPermanentRedirect, "/api/items?limit=5"},
+		{trailingSlashStrip, "/api/items", http.StatusOK, ""},
+		{trailingSlashRequire, "/api/items", http.StatusPermanentRedirect, "/api/items/"},
+		{trailingSlashRequire, "/api/items/", http.StatusOK, ""},
+		// Subtree patterns outside /api keep their slash under either policy.
+		{trailingSlashStrip, "/debug/pprof/", http.StatusOK, ""},
+		{trailingSlashRequire, "/debug/pprof/", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		h := withTrailingSlash(mux, tt.policy, "/api")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s: status = %d, want %d", tt.policy, tt.path, rec.Code, tt.wantStatus)
+		}
+		if loc := rec.Header().Get("Location"); loc != tt.wantLocation {
+			t.Errorf("%s %s: Location = %q, want %q", tt.policy, tt.path, loc, tt.wantLocation)
+		}
+	}
+}
+
+// TimeoutHandler returns while the handler is still running; recording the
+// route must not race with withLogging reading it. Run with -race.
+func TestRoutePatternWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/slow", func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		<-release
+	})
+
+	var pattern string
+	probe := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			// withLogging reads the pattern at this point.
+			if info, ok := r.Context().Value(routeInfoKey{}).(*routeInfo); ok {
+				pattern = info.pattern
+			}
+		})
+	}
+	h := withLogging(probe(withRoutePattern(withTimeout(mux, 10*time.Millisecond), mux)))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/slow", nil))
+	close(release)
+	<-done
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if pattern != "/api/slow" {
+		t.Errorf("pattern = %q, want /api/slow", pattern)
+	}
+}
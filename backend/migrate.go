@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// migration is a single forward-only schema change. Versions must be unique
+// and increasing; never edit a migration once it has shipped, add a new one.
+type migration struct {
+	version int
+	name    string
+	up      string
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create items",
+		up: `
+CREATE TABLE IF NOT EXISTS items (
+    id SERIAL PRIMARY KEY,
+    title TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);`,
+	},
+	{
+		version: 2,
+		name:    "add items.deleted_at",
+		up:      `ALTER TABLE items ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;`,
+	},
+	{
+		version: 3,
+		name:    "add items.updated_at",
+		up: `
+ALTER TABLE items ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+UPDATE items SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE items ALTER COLUMN updated_at SET DEFAULT now();
+ALTER TABLE items ALTER COLUMN updated_at SET NOT NULL;`,
+	},
+	{
+		version: 4,
+		name:    "add items.description",
+		up:      `ALTER TABLE items ADD COLUMN IF NOT EXISTS description TEXT;`,
+	},
+	{
+		version: 5,
+		name:    "create audit_log",
+		up: `
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    item_id INTEGER NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS audit_log_item_id_idx ON audit_log (item_id, created_at);`,
+	},
+	{
+		version: 6,
+		name:    "create tags",
+		up: `
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS item_tags (
+    item_id INTEGER NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (item_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS item_tags_tag_id_idx ON item_tags (tag_id);`,
+	},
+	{
+		version: 7,
+		name:    "add items.owner_id",
+		up: `
+ALTER TABLE items ADD COLUMN IF NOT EXISTS owner_id TEXT;
+CREATE INDEX IF NOT EXISTS items_owner_id_idx ON items (owner_id);`,
+	},
+	{
+		// Partial so archived items don't block reusing their title. Fails,
+		// and so aborts startup, if live duplicates already exist.
+		version: 8,
+		name:    "unique items.title",
+		up:      `CREATE UNIQUE INDEX IF NOT EXISTS items_title_key ON items (title) WHERE deleted_at IS NULL;`,
+	},
+	{
+		// owner_id is '' rather than NULL when auth is off so it can sit in
+		// the primary key.
+		version: 9,
+		name:    "create idempotency_keys",
+		up: `
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    owner_id TEXT NOT NULL DEFAULT '',
+    key TEXT NOT NULL,
+    item_id INTEGER NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (owner_id, key)
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);`,
+	},
+	{
+		// Generated and STORED so it can't drift from the row; the explicit
+		// 'english' config keeps the expression immutable, which GENERATED
+		// requires.
+		version: 10,
+		name:    "add items.search_vector",
+		up: `
+ALTER TABLE items ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS items_search_vector_idx ON items USING GIN (search_vector);`,
+	},
+	{
+		version: 11,
+		name:    "add items.version",
+		up:      `ALTER TABLE items ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;`,
+	},
+	{
+		// audit_log_action_check is the name Postgres gave the inline CHECK
+		// in migration 5.
+		version: 12,
+		name:    "allow restore in audit_log.action",
+		up: `
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore'));`,
+	},
+}
+
+// latestMigrationVersion is the schema version this binary expects.
+func latestMigrationVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrationLockID is an arbitrary key for pg_advisory_xact_lock so replicas
+// starting at the same time don't apply the same migration twice.
+const migrationLockID = 727274
+
+func migrate(db *sql.DB) error {
+	ctx := context.Background()
+
+	const q = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);`
+	if _, err := db.ExecContext(ctx, q); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		if err := applyMigration(ctx, db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// migrateDryRun writes every migration not yet recorded in
+// schema_migrations to out, SQL included, without changing the database.
+func migrateDryRun(db *sql.DB, out io.Writer) error {
+	ctx := context.Background()
+
+	applied := make(map[int]bool)
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v int
+			if err := rows.Scan(&v); err != nil {
+				return err
+			}
+			applied[v] = true
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	var pending int
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		pending++
+		if _, err := fmt.Fprintf(out, "-- migration %d: %s\n%s\n\n", m.version, m.name, strings.TrimSpace(m.up)); err != nil {
+			return err
+		}
+	}
+	slog.Info("migration dry run; nothing was applied", "pending", pending, "latest_version", latestMigrationVersion())
+	return nil
+}
+
+// applyMigration runs m in its own transaction. Any failure rolls the whole
+// migration back, so the schema is never left half-applied.
+func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Schema changes may legitimately outlast DB_STATEMENT_TIMEOUT, and
+	// waiting on another replica's lock certainly can.
+	if _, err := tx.ExecContext(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+
+	var applied bool
+	if err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`,
+		m.version,
+	).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return tx.Commit()
+	}
+
+	if _, err := tx.ExecContext(ctx, m.up); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	slog.Info("applied migration", "version", m.version, "name", m.name)
+	return nil
+}
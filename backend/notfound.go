@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var httpNotFoundTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_not_found_total",
+		Help: "Requests that matched no route, by first path segment; a spike usually means a scanner.",
+	},
+	[]string{"prefix"},
+)
+
+const (
+	maxNotFoundPrefixes  = 64
+	maxNotFoundPrefixLen = 32
+)
+
+// notFoundGuard counts requests that match no route and, with a threshold
+// set, blocks an IP that racks up threshold of them within window.
+type notFoundGuard struct {
+	threshold int
+	window    time.Duration
+	blockFor  time.Duration
+
+	mu       sync.Mutex
+	clients  map[string]*notFoundClient
+	prefixes map[string]bool
+}
+
+type notFoundClient struct {
+	count        int
+	windowStart  time.Time
+	blockedUntil time.Time
+}
+
+// newNotFoundGuard returns a guard that only counts when threshold is 0.
+func newNotFoundGuard(threshold int, window, blockFor time.Duration) *notFoundGuard {
+	return &notFoundGuard{
+		threshold: threshold,
+		window:    window,
+		blockFor:  blockFor,
+		clients:   make(map[string]*notFoundClient),
+		prefixes:  make(map[string]bool),
+	}
+}
+
+// prefixLabel is the first path segment, e.g. "/wp-admin". Scanners probe
+// endless paths, so once maxNotFoundPrefixes distinct labels exist the
+// rest share "other".
+func (g *notFoundGuard) prefixLabel(path string) string {
+	seg, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if len(seg) > maxNotFoundPrefixLen {
+		seg = seg[:maxNotFoundPrefixLen]
+	}
+	label := "/" + strings.ToLower(seg)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.prefixes[label] {
+		if len(g.prefixes) >= maxNotFoundPrefixes {
+			return "other"
+		}
+		g.prefixes[label] = true
+	}
+	return label
+}
+
+func (g *notFoundGuard) blocked(ip string, now time.Time) (time.Duration, bool) {
+	if g.threshold <= 0 {
+		return 0, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c, ok := g.clients[ip]
+	if !ok || !now.Before(c.blockedUntil) {
+		return 0, false
+	}
+	return c.blockedUntil.Sub(now), true
+}
+
+func (g *notFoundGuard) record(ip string, now time.Time) {
+	if g.threshold <= 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c, ok := g.clients[ip]
+	if !ok || now.Sub(c.windowStart) > g.window {
+		c = &notFoundClient{windowStart: now}
+		g.clients[ip] = c
+	}
+	c.count++
+	if c.count >= g.threshold {
+		c.blockedUntil = now.Add(g.blockFor)
+	}
+}
+
+// cleanup forgets clients whose window and block are both over, every
+// minute until ctx is cancelled.
+func (g *notFoundGuard) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			g.mu.Lock()
+			for ip, c := range g.clients {
+				if now.Sub(c.windowStart) > g.window && !now.Before(c.blockedUntil) {
+					delete(g.clients, ip)
+				}
+			}
+			g.mu.Unlock()
+		}
+	}
+}
+
+// withNotFoundGuard sits inside withLogging, which records the matched
+// route; a 404 with no route is a miss, while an API 404 for a missing item
+// is not.
+func withNotFoundGuard(next http.Handler, g *notFoundGuard) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		if wait, ok := g.blocked(ip, time.Now()); ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			writeJSONError(w, http.StatusForbidden, "too many requests for unknown paths")
+			return
+		}
+
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r)
+
+		info, _ := r.Context().Value(routeInfoKey{}).(*routeInfo)
+		if rw.status == http.StatusNotFound && (info == nil || info.pattern == "") {
+			httpNotFoundTotal.WithLabelValues(g.prefixLabel(r.URL.Path)).Inc()
+			g.record(ip, time.Now())
+		}
+	})
+}
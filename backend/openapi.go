@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// openAPISchemas are the component schemas, generated from the Go types the
+// handlers actually encode and decode so the spec can't drift from them.
+var openAPISchemas = map[string]reflect.Type{
+	"Item":                reflect.TypeFor[Item](),
+	"ItemPage":            reflect.TypeFor[itemPage](),
+	"ListEnvelope":        reflect.TypeFor[listEnvelope](),
+	"CreateItemRequest":   reflect.TypeFor[createItemRequest](),
+	"UpdateItemRequest":   reflect.TypeFor[updateItemRequest](),
+	"DeleteItemsRequest":  reflect.TypeFor[deleteItemsRequest](),
+	"DeleteItemsResponse": reflect.TypeFor[deleteItemsResponse](),
+	"TagItemsRequest":     reflect.TypeFor[tagItemsRequest](),
+	"TagItemsResponse":    reflect.TypeFor[tagItemsResponse](),
+	"PatchItemRequest":    reflect.TypeFor[patchItemRequest](),
+	"SearchResult":        reflect.TypeFor[searchResult](),
+	"AuditEntry":          reflect.TypeFor[AuditEntry](),
+	"ImportSummary":       reflect.TypeFor[importSummary](),
+	"TagCount":            reflect.TypeFor[tagCount](),
+	"ItemStats":           reflect.TypeFor[itemStats](),
+	"DeleteAllResponse":   reflect.TypeFor[deleteAllResponse](),
+	"Error":               reflect.TypeFor[errorResponse](),
+	"DuplicateTitle":      reflect.TypeFor[duplicateTitleResponse](),
+	"ValidationError":     reflect.TypeFor[ValidationError](),
+}
+
+// requestRequired overrides the generated required list for request bodies.
+// decodeJSON leaves absent fields at their zero value, so omitempty says
+// nothing about what a client must send; what's required is whatever the
+// handler validates.
+var requestRequired = map[string][]string{
+	"CreateItemRequest": {"title"},
+	"UpdateItemRequest": {"title", "version"},
+	"PatchItemRequest":  {"version"},
+	"TagItemsRequest":   {"ids", "tag"},
+}
+
+// schemaRefs maps each component type back to its $ref.
+var schemaRefs = func() map[reflect.Type]string {
+	refs := make(map[reflect.Type]string, len(openAPISchemas))
+	for name, t := range openAPISchemas {
+		refs[t] = "#/components/schemas/" + name
+	}
+	return refs
+}()
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// jsonSchema describes t the way encoding/json would encode it. Named
+// component types are referenced rather than inlined unless inline is set.
+func jsonSchema(t reflect.Type, inline bool) map[string]any {
+	if ref, ok := schemaRefs[t]; ok && !inline {
+		return map[string]any{"$ref": ref}
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := jsonSchema(t.Elem(), false)
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem(), false)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem(), false)}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return map[string]any{}
+}
+
+// structSchema follows encoding/json's field rules: the json tag names the
+// property, "-" hides it, omitempty makes it optional and embedded structs
+// are flattened.
+func structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := structSchema(f.Type)
+			for k, v := range embedded["properties"].(map[string]any) {
+				props[k] = v
+			}
+			if req, ok := embedded["required"].([]string); ok {
+				required = append(required, req...)
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = jsonSchema(f.Type, false)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func response(desc string, schema map[string]any) map[string]any {
+	r := map[string]any{"description": desc}
+	if schema != nil {
+		r["content"] = jsonContent(schema)
+	}
+	return r
+}
+
+func errorResponses(statuses ...string) map[string]any {
+	out := map[string]any{}
+	for _, s := range statuses {
+		out[s] = map[string]any{"$ref": "#/components/responses/Error"}
+	}
+	return out
+}
+
+func operation(summary string, params []any, body map[string]any, ok map[string]any, errStatuses ...string) map[string]any {
+	responses := errorResponses(errStatuses...)
+	for status, r := range ok {
+		responses[status] = r
+	}
+	op := map[string]any{"summary": summary, "responses": responses}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if body != nil {
+		op["requestBody"] = map[string]any{"required": true, "content": jsonContent(body)}
+		responses["415"] = map[string]any{"$ref": "#/components/responses/Error"}
+	}
+	return op
+}
+
+func queryParam(name, typ, desc string) map[string]any {
+	return map[string]any{"name": name, "in": "query", "description": desc, "schema": map[string]any{"type": typ}}
+}
+
+var itemIDParam = map[string]any{
+	"name": "id", "in": "path", "required": true,
+	"schema": map[string]any{"type": "integer", "format": "int64", "minimum": 1},
+}
+
+// listParams are the query parameters itemFilters, parsePagination and
+// parseSort understand.
+var listParams = []any{
+	queryParam("q", "string", "Case-insensitive title substring."),
+	queryParam("tag", "string", "Only items carrying this tag."),
+	queryParam("from", "string", "created_at lower bound, RFC3339 or YYYY-MM-DD."),
+	queryParam("to", "string", "created_at upper bound (exclusive), RFC3339 or YYYY-MM-DD."),
+	queryParam("include_deleted", "boolean", "Include soft-deleted items; admins only, ignored for anyone else."),
+	queryParam("limit", "integer", "Page size; clamped to MAX_PAGE_SIZE."),
+	queryParam("offset", "integer", "Rows to skip; ignored in cursor mode."),
+	queryParam("sort", "string", "created_at, title or id."),
+	queryParam("order", "string", "asc or desc."),
+	queryParam("cursor", "string", "Opaque keyset cursor; pass empty for the first page."),
+	queryParam("envelope", "boolean", "Wrap the page in a ListEnvelope."),
+	queryParam("ids", "string", "Comma-separated ids to fetch instead of listing; other list parameters except include_deleted are ignored, and results follow the requested order."),
+}
+
+func buildOpenAPI() map[string]any {
+	schemas := make(map[string]any, len(openAPISchemas))
+	for name, t := range openAPISchemas {
+		s := jsonSchema(t, true)
+		if req, ok := requestRequired[name]; ok {
+			delete(s, "required")
+			if len(req) > 0 {
+				s["required"] = req
+			}
+		}
+		schemas[name] = s
+	}
+
+	ok := func(status, desc string, schema map[string]any) map[string]any {
+		return map[string]any{status: response(desc, schema)}
+	}
+	items := map[string]any{"type": "array", "items": ref("Item")}
+	count := map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"count": map[string]any{"type": "integer", "format": "int64"}},
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Items API",
+			"version": gitCommit,
+		},
+		"paths": map[string]any{
+			"/api/items": map[string]any{
+				"get": operation("List items", listParams, nil,
+					map[string]any{
+						"200": response("A bare array, an ItemPage in cursor mode, or a ListEnvelope.", map[string]any{
+							"oneOf": []any{items, ref("ItemPage"), ref("ListEnvelope")},
+						}),
+						"304": response("No item changed since If-Modified-Since.", nil),
+					}, "400", "504"),
+				"post": operation("Create an item", []any{
+					queryParam("check_duplicates", "boolean", "Refuse with 409 if a live item has the same title ignoring case."),
+					queryParam("force", "boolean", "Skip the check_duplicates check."),
+				}, ref("CreateItemRequest"),
+					map[string]any{
+						"201": response("Created.", ref("Item")),
+						"200": response("Replayed Idempotency-Key; the original item.", ref("Item")),
+						"409": response("Title already taken, or a likely duplicate under check_duplicates.", map[string]any{
+							"oneOf": []any{ref("Error"), ref("DuplicateTitle")},
+						}),
+					}, "400", "413", "504"),
+				"delete": operation("Soft-delete many items", nil, ref("DeleteItemsRequest"),
+					ok("200", "How many were deleted and which ids were not found.", ref("DeleteItemsResponse")), "400", "413", "504"),
+			},
+			"/api/items/{id}": map[string]any{
+				"parameters": []any{itemIDParam},
+				"get": operation("Get an item", []any{queryParam("include_deleted", "boolean", "Return the item even if soft-deleted; admins only.")}, nil,
+					map[string]any{
+						"200": response("The item.", ref("Item")),
+						"304": response("Not modified since If-None-Match.", nil),
+					}, "400", "404"),
+				"put": operation("Replace an item", nil, ref("UpdateItemRequest"),
+					ok("200", "The updated item.", ref("Item")), "400", "404", "409"),
+				"patch": operation("Update only the fields present", nil, ref("PatchItemRequest"),
+					ok("200", "The updated item.", ref("Item")), "400", "404", "409"),
+				"delete": operation("Soft-delete an item", nil, nil,
+					map[string]any{"204": response("Deleted.", nil)}, "400", "404"),
+			},
+			"/api/items/{id}/history": map[string]any{
+				"parameters": []any{itemIDParam},
+				"get": operation("Audit history of an item", nil, nil,
+					ok("200", "Oldest first.", map[string]any{"type": "array", "items": ref("AuditEntry")}), "400", "404"),
+			},
+			"/api/items/{id}/restore": map[string]any{
+				"parameters": []any{itemIDParam},
+				"post": operation("Undo a soft delete", nil, nil,
+					ok("200", "The restored item.", ref("Item")), "400", "404", "409"),
+			},
+			"/api/items/bulk": map[string]any{
+				"post": operation("Create many items atomically", nil,
+					map[string]any{"type": "array", "items": ref("CreateItemRequest"), "maxItems": maxBulkItems},
+					ok("201", "The created items, in request order.", items), "400", "409", "413"),
+			},
+			"/api/items/tag": map[string]any{
+				"post": operation("Add a tag to many items", nil, ref("TagItemsRequest"),
+					ok("200", "How many items gained the tag and which ids were skipped.", ref("TagItemsResponse")), "400", "413", "504"),
+			},
+			"/api/items/count": map[string]any{
+				"get": operation("Count items matching the list filters", listParams[:5], nil,
+					ok("200", "The count.", count), "400"),
+			},
+			"/api/items/search": map[string]any{
+				"get": operation("Full-text search over title and description",
+					append([]any{queryParam("q", "string", "Search terms; required.")}, listParams[5:7]...), nil,
+					ok("200", "Best matches first.", map[string]any{"type": "array", "items": ref("SearchResult")}), "400", "504"),
+			},
+			"/api/items/all": map[string]any{
+				"delete": operation("Wipe every item; admin only, never in production", []any{map[string]any{
+					"name": confirmDeleteAllHeader, "in": "header", "required": true,
+					"schema": map[string]any{"type": "string", "enum": []string{"yes"}},
+				}}, nil, ok("200", "How many items existed, soft-deleted ones included.", ref("DeleteAllResponse")), "400", "403"),
+			},
+			"/api/items/recent": map[string]any{
+				"get": operation("Items created within a recent window",
+					append([]any{queryParam("window", "string", "Go duration or Nd, default 24h, at most 30d.")}, listParams[5:7]...), nil,
+					ok("200", "Newest first.", items), "400", "504"),
+			},
+			"/api/tags": map[string]any{
+				"get": operation("Tags on live items with their item counts",
+					[]any{queryParam("limit", "integer", "Only the N most used tags.")}, nil,
+					ok("200", "Most used first.", map[string]any{"type": "array", "items": ref("TagCount")}), "504"),
+			},
+			"/api/stats": map[string]any{
+				"get": operation("Aggregate counts over live items, cached for a few seconds", nil, nil,
+					ok("200", "The aggregates.", ref("ItemStats")), "504"),
+			},
+			"/api/items/export": map[string]any{
+				"get": operation("Export items as JSON or CSV",
+					append([]any{queryParam("format", "string", "json (default) or csv.")}, listParams[:5]...), nil,
+					ok("200", "Every matching item.", items), "400"),
+			},
+			"/api/items/import": map[string]any{
+				"post": map[string]any{
+					"summary": "Import items from CSV",
+					"parameters": []any{
+						queryParam("skip_duplicates", "boolean", "Skip rows whose title already exists."),
+					},
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							"text/csv": map[string]any{"schema": map[string]any{"type": "string"}},
+							"multipart/form-data": map[string]any{"schema": map[string]any{
+								"type":       "object",
+								"properties": map[string]any{"file": map[string]any{"type": "string", "format": "binary"}},
+							}},
+						},
+					},
+					"responses": func() map[string]any {
+						r := errorResponses("400", "413", "504")
+						r["200"] = response("Per-row outcome; taken titles are row failures.", ref("ImportSummary"))
+						return r
+					}(),
+				},
+			},
+		},
+		"components": map[string]any{
+			"schemas": schemas,
+			"responses": map[string]any{
+				"Error": response("Error; validation failures also carry errors.", ref("Error")),
+			},
+		},
+	}
+}
+
+var openAPIDoc = sync.OnceValue(func() []byte {
+	b, err := json.Marshal(buildOpenAPI())
+	if err != nil {
+		panic(err)
+	}
+	return b
+})
+
+// handleOpenAPI serves the OpenAPI 3 description of the items API.
+func (a *App) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPIDoc())
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// patchItemRequest distinguishes an absent field (nil) from one set to its
+// zero value, so PATCH only touches what the client sent.
+type patchItemRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Version     *int64  `json:"version"`
+}
+
+// patchItem applies a partial update. Unlike updateItem, fields missing from
+// the body keep their current value.
+func (a *App) patchItem(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	defer r.Body.Close()
+
+	id, err := parseItemID(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req patchItemRequest
+	if !a.decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs ValidationErrors
+	v := a.validateItem(&errs, "", itemInput{Title: req.Title, Description: req.Description}, true)
+	checkVersion(&errs, req.Version)
+
+	q := &itemQuery{}
+	var sets []string
+	if v.Title != nil {
+		sets = append(sets, `title = `+q.arg(*v.Title))
+	}
+	if v.Description != nil {
+		sets = append(sets, `description = NULLIF(`+q.arg(*v.Description)+`, '')`)
+	}
+	if !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+	if len(sets) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "no updatable fields provided")
+		return
+	}
+
+	query := `UPDATE items SET ` + strings.Join(sets, ", ") + `, updated_at = now(), version = version + 1
+		 WHERE id = ` + q.arg(id) + ` AND version = ` + q.arg(*req.Version) + ` AND deleted_at IS NULL AND ` + ownerMatches(q.arg(ownerFilter(r))) +
+		` RETURNING ` + itemColumns
+
+	var item Item
+	err = a.inTx(r.Context(), func(tx *sql.Tx) error {
+		if err := scanItem(tx.QueryRowContext(r.Context(), query, q.args...), &item); err != nil {
+			return err
+		}
+		if err := recordAudit(r.Context(), tx, item.ID, auditUpdate, item); err != nil {
+			return err
+		}
+		return notifyItemEvent(r.Context(), tx, auditUpdate, item.ID)
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		a.writeUpdateMiss(w, r, id)
+		return
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeJSONError(w, http.StatusConflict, errDuplicateTitle)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to patch item", "item_id", id, "err", err)
+		writeDBError(w, err, "failed to update item")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(item)
+}
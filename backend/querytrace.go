@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxLoggedSQLLen truncates the statement text in slow-query log lines.
+const maxLoggedSQLLen = 500
+
+// queryTracer times every statement pgx runs, including those inside
+// transactions and prepared statements, without touching call sites. It
+// feeds dbQueryDuration and logs statements slower than slow; slow <= 0
+// turns the logging off.
+type queryTracer struct {
+	slow time.Duration
+}
+
+type queryTraceKey struct{}
+
+type queryTrace struct {
+	start time.Time
+	sql   string
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{start: time.Now(), sql: data.SQL})
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	qt, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(qt.start)
+
+	outcome := "ok"
+	if data.Err != nil {
+		outcome = "error"
+	}
+	dbQueryDuration.WithLabelValues(statementKind(qt.sql), outcome).Observe(elapsed.Seconds())
+
+	switch {
+	case t.slow > 0 && elapsed >= t.slow:
+		slog.WarnContext(ctx, "slow query", "duration", elapsed, "sql", compactSQL(qt.sql))
+	case slog.Default().Enabled(ctx, slog.LevelDebug):
+		// Every statement is far too chatty for info.
+		slog.DebugContext(ctx, "query", "duration", elapsed, "sql", compactSQL(qt.sql), "err", data.Err)
+	}
+}
+
+// statementKind is the lowercased leading keyword, e.g. "select", used as a
+// low-cardinality metric label.
+func statementKind(sql string) string {
+	word, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	switch kind := strings.ToLower(word); kind {
+	case "select", "insert", "update", "delete", "with", "begin", "commit", "rollback":
+		return kind
+	}
+	return "other"
+}
+
+// compactSQL folds the indentation of multi-line query literals onto one
+// line and truncates it for logging.
+func compactSQL(sql string) string {
+	s := strings.Join(strings.Fields(sql), " ")
+	if len(s) > maxLoggedSQLLen {
+		s = s[:maxLoggedSQLLen] + "..."
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRecentWindow = 24 * time.Hour
+	maxRecentWindow     = 30 * 24 * time.Hour
+)
+
+// parseRecentWindow reads ?window= as a Go duration, plus a "d" suffix for
+// whole days since time.ParseDuration has none. Windows past
+// maxRecentWindow are clamped to it.
+func parseRecentWindow(r *http.Request) (time.Duration, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get("window"))
+	if raw == "" {
+		return defaultRecentWindow, nil
+	}
+
+	var d time.Duration
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("window must be a duration such as 24h or 7d, got %q", raw)
+		}
+		// Clamp before multiplying so huge values can't overflow.
+		d = time.Duration(min(n, 31)) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(raw); err != nil {
+			return 0, fmt.Errorf("window must be a duration such as 24h or 7d, got %q", raw)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("window must be positive, got %q", raw)
+	}
+	return min(d, maxRecentWindow), nil
+}
+
+// recentItems lists live items created within ?window= of now, newest
+// first, for activity widgets that would otherwise compute ?from= themselves.
+func (a *App) recentItems(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	window, err := parseRecentWindow(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, offset := a.parsePagination(r)
+
+	var items []Item
+	err = a.retryRead(r.Context(), "list recent items", func() error {
+		var err error
+		items, err = a.queryItems(
+			r.Context(),
+			`SELECT `+itemColumns+` FROM items
+			 WHERE created_at >= now() - make_interval(secs => $1) AND deleted_at IS NULL AND `+ownerMatches("$2")+`
+			 ORDER BY created_at DESC, id DESC
+			 LIMIT $3 OFFSET $4`,
+			window.Seconds(),
+			ownerFilter(r),
+			limit,
+			offset,
+		)
+		return err
+	})
+	if err != nil {
+		if clientGone(r) {
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to list recent items", "err", err)
+		writeDBError(w, err, "failed to load items")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
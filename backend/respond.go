@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+type errorResponse struct {
+	Error  string            `json:"error"`
+	Errors []ValidationError `json:"errors,omitempty"`
+}
+
+// writeJSONError is the JSON counterpart of http.Error; every error the API
+// returns goes through it so clients only ever parse {"error": "..."}.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeError(w, status, errorResponse{Error: message})
+}
+
+func writeError(w http.ResponseWriter, status int, body errorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+const ndjsonContentType = "application/x-ndjson"
+
+// accepts reports whether r's Accept header explicitly lists mediaType with a
+// non-zero q. Wildcards don't count, so clients only get a non-default
+// format when they ask for it by name.
+func accepts(r *http.Request, mediaType string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mt != mediaType {
+			continue
+		}
+		if q, ok := params["q"]; ok && strings.Trim(q, "0.") == "" {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// wantsXML reports whether the client asked for XML rather than JSON. An
+// Accept that also names application/json, or only wildcards, gets JSON.
+func wantsXML(r *http.Request) bool {
+	return (accepts(r, "application/xml") || accepts(r, "text/xml")) && !accepts(r, "application/json")
+}
+
+// writeXML is the XML counterpart of encoding a 200 JSON body. Errors stay
+// JSON regardless; legacy XML clients only need the success shapes.
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to encode XML response", "err", err)
+	}
+}
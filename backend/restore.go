@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+func (a *App) handleRestore(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.restoreItem(w, r)
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// restoreItem undoes a soft delete. It answers 409 for an item that isn't
+// deleted, and also when a live item has taken the title in the meantime.
+func (a *App) restoreItem(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	id, err := parseItemID(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var item Item
+	err = a.inTx(r.Context(), func(tx *sql.Tx) error {
+		if err := scanItem(tx.QueryRowContext(
+			r.Context(),
+			`UPDATE items SET deleted_at = NULL, updated_at = now(), version = version + 1
+			 WHERE id = $1 AND deleted_at IS NOT NULL AND `+ownerMatches("$2")+` RETURNING `+itemColumns,
+			id,
+			ownerFilter(r),
+		), &item); err != nil {
+			return err
+		}
+		if err := recordAudit(r.Context(), tx, item.ID, auditRestore, item); err != nil {
+			return err
+		}
+		return notifyItemEvent(r.Context(), tx, auditRestore, item.ID)
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		a.writeRestoreMiss(w, r, id)
+		return
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeJSONError(w, http.StatusConflict, errDuplicateTitle)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to restore item", "item_id", id, "err", err)
+		writeDBError(w, err, "failed to restore item")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(item)
+}
+
+// writeRestoreMiss answers a restore that matched no deleted row: 409 if the
+// item exists but is live, otherwise 404.
+func (a *App) writeRestoreMiss(w http.ResponseWriter, r *http.Request, id int64) {
+	var exists bool
+	err := a.db.QueryRowContext(
+		r.Context(),
+		`SELECT EXISTS (SELECT 1 FROM items WHERE id = $1 AND `+ownerMatches("$2")+`)`,
+		id,
+		ownerFilter(r),
+	).Scan(&exists)
+	switch {
+	case err != nil:
+		slog.ErrorContext(r.Context(), "failed to check item", "item_id", id, "err", err)
+		writeDBError(w, err, "failed to restore item")
+	case exists:
+		writeJSONError(w, http.StatusConflict, "item is not deleted")
+	default:
+		writeJSONError(w, http.StatusNotFound, "item not found")
+	}
+}
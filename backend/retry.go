@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const retryBaseDelay = 50 * time.Millisecond
+
+// isTransient reports whether err is worth retrying for an idempotent read:
+// the statement never reached the server, the connection dropped, or the
+// server is restarting.
+func isTransient(err error) bool {
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgerrcode.IsConnectionException(pgErr.Code) ||
+			pgErr.Code == pgerrcode.AdminShutdown ||
+			pgErr.Code == pgerrcode.CannotConnectNow ||
+			isSerializationFailure(err)
+	}
+	return false
+}
+
+// isSerializationFailure reports whether a transaction lost a conflict with
+// another one and can be rerun from the start.
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		(pgErr.Code == pgerrcode.SerializationFailure || pgErr.Code == pgerrcode.DeadlockDetected)
+}
+
+// retry runs fn until it succeeds, fails with an error retryable rejects,
+// or a.dbRetries retries are used up, doubling a short backoff in between.
+// fn must be safe to run more than once.
+func (a *App) retry(ctx context.Context, op string, retryable func(error) bool, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= a.dbRetries || !retryable(err) {
+			return err
+		}
+
+		slog.WarnContext(
+			ctx,
+			"retrying after transient error",
+			"op", op,
+			"attempt", attempt+1,
+			"max_retries", a.dbRetries,
+			"err", err,
+		)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// retryRead retries an idempotent read on transient errors.
+func (a *App) retryRead(ctx context.Context, op string, fn func() error) error {
+	return a.retry(ctx, op, isTransient, fn)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// searchResult is an Item plus its ts_rank relevance; higher is better.
+type searchResult struct {
+	Item
+	Rank float64 `json:"rank"`
+}
+
+// searchItems runs a full-text query over title and description, best
+// matches first. Titles weigh more than descriptions (see migration 10).
+func (a *App) searchItems(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	term := strings.TrimSpace(r.URL.Query().Get("q"))
+	if term == "" {
+		writeJSONError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit, offset := a.parsePagination(r)
+
+	var results []searchResult
+	err := a.retryRead(r.Context(), "search items", func() error {
+		var err error
+		results, err = a.querySearch(r.Context(), term, ownerFilter(r), limit, offset)
+		return err
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to search items", "err", err)
+		writeDBError(w, err, "failed to search items")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(results)
+}
+
+// querySearch runs the ranked full-text query, best matches first.
+func (a *App) querySearch(ctx context.Context, term string, owner sql.NullString, limit, offset int) ([]searchResult, error) {
+	rows, err := a.db.QueryContext(
+		ctx,
+		`SELECT `+itemColumns+`, ts_rank(search_vector, query) AS rank
+		 FROM items, plainto_tsquery('english', $1) AS query
+		 WHERE search_vector @@ query AND deleted_at IS NULL AND `+ownerMatches("$2")+`
+		 ORDER BY rank DESC, id DESC
+		 LIMIT $3 OFFSET $4`,
+		term,
+		owner,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]searchResult, 0)
+	for rows.Next() {
+		var res searchResult
+		if err := scanItem(rows, &res.Item, &res.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
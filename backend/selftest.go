@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const selfTestTimeout = 10 * time.Second
+
+// startupSelfTest inserts, reads back and deletes a probe item to prove the
+// DB role has the grants the API needs, not just connect rights. It runs in
+// a transaction that is always rolled back, so nothing is left behind and
+// no audit row or notification escapes.
+func startupSelfTest(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), selfTestTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	title := "__selftest__ " + uuid.NewString()
+	var id int64
+	if err := tx.QueryRowContext(ctx, `INSERT INTO items (title) VALUES ($1) RETURNING id`, title).Scan(&id); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+
+	var got string
+	if err := tx.QueryRowContext(ctx, `SELECT title FROM items WHERE id = $1`, id).Scan(&got); err != nil {
+		return fmt.Errorf("select: %w", err)
+	}
+	if got != title {
+		return fmt.Errorf("select: read back %q, want %q", got, title)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM items WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return fmt.Errorf("delete: removed %d rows, want 1", n)
+	}
+
+	slog.Info("startup self-test passed: insert, select and delete all work")
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// statsCacheTTL bounds how stale /api/stats can be; a polling dashboard
+// then costs at most one query per owner every few seconds.
+const statsCacheTTL = 5 * time.Second
+
+// itemStats is the /api/stats response. "Today" and "this week" follow the
+// database session's time zone; weeks start on Monday.
+type itemStats struct {
+	Total           int64      `json:"total"`
+	CreatedToday    int64      `json:"created_today"`
+	CreatedThisWeek int64      `json:"created_this_week"`
+	LatestCreatedAt *time.Time `json:"latest_created_at"`
+}
+
+type statsEntry struct {
+	stats   itemStats
+	expires time.Time
+}
+
+// statsCache holds recent stats per owner filter, so scoped callers never
+// see each other's numbers.
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[sql.NullString]statsEntry
+}
+
+func newStatsCache() *statsCache {
+	return &statsCache{entries: make(map[sql.NullString]statsEntry)}
+}
+
+func (c *statsCache) get(owner sql.NullString, now time.Time) (itemStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[owner]
+	if !ok || !now.Before(e.expires) {
+		return itemStats{}, false
+	}
+	return e.stats, true
+}
+
+// put stores s and drops expired entries, which keeps the map bounded by
+// the owners active within one TTL.
+func (c *statsCache) put(owner sql.NullString, s itemStats, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[owner] = statsEntry{stats: s, expires: now.Add(statsCacheTTL)}
+}
+
+// handleStats returns aggregate counts over the caller's live items.
+func (a *App) handleStats(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	owner := ownerFilter(r)
+	now := time.Now()
+	s, ok := a.stats.get(owner, now)
+	if !ok {
+		err := a.retryRead(r.Context(), "item stats", func() error {
+			var err error
+			s, err = a.queryStats(r.Context(), owner)
+			return err
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to compute stats", "err", err)
+			writeDBError(w, err, "failed to compute stats")
+			return
+		}
+		a.stats.put(owner, s, now)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+// queryStats computes every figure in a single scan of the live items.
+func (a *App) queryStats(ctx context.Context, owner sql.NullString) (itemStats, error) {
+	var s itemStats
+	var latest sql.NullTime
+	err := a.db.QueryRowContext(
+		ctx,
+		`WITH live AS (
+			SELECT created_at FROM items
+			WHERE deleted_at IS NULL AND `+ownerMatches("$1")+`
+		 )
+		 SELECT COUNT(*),
+		        COUNT(*) FILTER (WHERE created_at >= date_trunc('day', now())),
+		        COUNT(*) FILTER (WHERE created_at >= date_trunc('week', now())),
+		        max(created_at)
+		 FROM live`,
+		owner,
+	).Scan(&s.Total, &s.CreatedToday, &s.CreatedThisWeek, &latest)
+	if err != nil {
+		return itemStats{}, err
+	}
+	if latest.Valid {
+		s.LatestCreatedAt = &latest.Time
+	}
+	return s, nil
+}
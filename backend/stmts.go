@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+)
+
+var getItemSQL = `SELECT ` + itemColumns + ` FROM items
+		 WHERE id = $1 AND ($2 OR deleted_at IS NULL) AND ` + ownerMatches("$3")
+
+// $4 overrides created_at; NULL means now(). updated_at is always now(), even
+// for a backdated import, since itemsLastModified relies on it moving on
+// every write.
+const insertItemSQL = `INSERT INTO items (title, description, owner_id, created_at, updated_at)
+		VALUES ($1, NULLIF($2, ''), $3, COALESCE($4::timestamptz, now()), now())
+		RETURNING ` + itemColumns
+
+// preparedStmts holds the fixed hot-path statements, prepared once at
+// startup. A nil field means preparing failed and callers fall back to the
+// plain query text. The list query is assembled per request from its
+// filters, so it isn't prepared here.
+type preparedStmts struct {
+	getItem    *sql.Stmt
+	insertItem *sql.Stmt
+}
+
+func prepareStmts(ctx context.Context, db *sql.DB) *preparedStmts {
+	prepare := func(name, query string) *sql.Stmt {
+		stmt, err := db.PrepareContext(ctx, query)
+		if err != nil {
+			slog.Warn("failed to prepare statement, using unprepared queries", "statement", name, "err", err)
+			return nil
+		}
+		return stmt
+	}
+	return &preparedStmts{
+		getItem:    prepare("getItem", getItemSQL),
+		insertItem: prepare("insertItem", insertItemSQL),
+	}
+}
+
+func (s *preparedStmts) close() {
+	for _, stmt := range []*sql.Stmt{s.getItem, s.insertItem} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil {
+			slog.Error("failed to close prepared statement", "err", err)
+		}
+	}
+}
+
+// queryRow runs stmt, or query on the pool when stmt wasn't prepared.
+func (a *App) queryRow(ctx context.Context, stmt *sql.Stmt, query string, args ...any) *sql.Row {
+	if stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return a.db.QueryRowContext(ctx, query, args...)
+}
+
+// queryRowTx is queryRow inside tx.
+func queryRowTx(ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, query string, args ...any) *sql.Row {
+	if stmt != nil {
+		return tx.StmtContext(ctx, stmt).QueryRowContext(ctx, args...)
+	}
+	return tx.QueryRowContext(ctx, query, args...)
+}
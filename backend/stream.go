@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const sseHeartbeatInterval = 15 * time.Second
+
+// streamItems pushes newly created items to the client as Server-Sent
+// Events. Comment-only heartbeats keep idle proxies from closing the
+// connection.
+func (a *App) streamItems(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's WriteTimeout by design.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.WarnContext(r.Context(), "failed to clear write deadline for stream", "err", err)
+	}
+
+	events := a.events.subscribe()
+	defer a.events.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	owner := ownerFilter(r)
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-a.draining:
+			// EventSource reconnects on its own; the comment just tells
+			// anyone watching the stream why it ended.
+			_, _ = fmt.Fprint(w, ": server shutting down\n\n")
+			_ = rc.Flush()
+			return
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+
+		case ev := <-events:
+			if ev.Action != auditCreate {
+				continue
+			}
+			var item Item
+			err := scanItem(a.db.QueryRowContext(
+				r.Context(),
+				`SELECT `+itemColumns+` FROM items WHERE id = $1 AND deleted_at IS NULL AND `+ownerMatches("$2"),
+				ev.ID,
+				owner,
+			), &item)
+			if errors.Is(err, sql.ErrNoRows) {
+				// Not visible to this client, or already deleted.
+				continue
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "failed to load streamed item", "item_id", ev.ID, "err", err)
+				continue
+			}
+
+			data, err := json.Marshal(item)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: item\ndata: %s\n\n", item.ID, data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// itemTagsColumn aggregates an item's tag names as a JSON array so a single
+// row carries them without a second query. It expects the outer table to be
+// referenced as items.
+const itemTagsColumn = `COALESCE((
+	SELECT json_agg(t.name ORDER BY t.name)
+	FROM item_tags it JOIN tags t ON t.id = it.tag_id
+	WHERE it.item_id = items.id
+), '[]')`
+
+// normalizeTags trims and lowercases tags, dropping blanks and duplicates so
+// "Work" and " work " end up as the same tag row.
+func normalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// addItemTags attaches tags to an item, creating tag rows that don't exist
+// yet. Both inserts are idempotent, so repeating a tag is harmless.
+func addItemTags(ctx context.Context, tx *sql.Tx, itemID int64, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`,
+		tags,
+	); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO item_tags (item_id, tag_id)
+		 SELECT $1, id FROM tags WHERE name = ANY($2)
+		 ON CONFLICT DO NOTHING`,
+		itemID,
+		tags,
+	)
+	return err
+}
+
+// tagCount is one entry of the /api/tags listing.
+type tagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// listTags returns every tag on a live item the caller can see, with how
+// many such items carry it, most used first. ?limit= keeps only the top N.
+func (a *App) listTags(w http.ResponseWriter, r *http.Request) {
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	// NULL means no limit; a bad value is ignored like in parsePagination.
+	var limit sql.NullInt64
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = sql.NullInt64{Int64: int64(v), Valid: true}
+	}
+
+	rows, err := a.db.QueryContext(
+		r.Context(),
+		`SELECT t.name, COUNT(*) AS n
+		 FROM tags t
+		 JOIN item_tags it ON it.tag_id = t.id
+		 JOIN items ON items.id = it.item_id
+		 WHERE items.deleted_at IS NULL AND `+ownerMatches("$1")+`
+		 GROUP BY t.name
+		 ORDER BY n DESC, t.name
+		 LIMIT $2`,
+		ownerFilter(r),
+		limit,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list tags", "err", err)
+		writeDBError(w, err, "failed to list tags")
+		return
+	}
+	defer rows.Close()
+
+	tags := make([]tagCount, 0)
+	for rows.Next() {
+		var tc tagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			slog.ErrorContext(r.Context(), "failed to scan tag count", "err", err)
+			writeDBError(w, err, "failed to list tags")
+			return
+		}
+		tags = append(tags, tc)
+	}
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(r.Context(), "failed to iterate tag counts", "err", err)
+		writeDBError(w, err, "failed to list tags")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tags)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidationError describes one rejected request field.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every problem with a request so clients can fix
+// them all in one round trip instead of one per attempt.
+type ValidationErrors []ValidationError
+
+func (v *ValidationErrors) Add(field, message string) {
+	*v = append(*v, ValidationError{Field: field, Message: message})
+}
+
+func (v ValidationErrors) Empty() bool {
+	return len(v) == 0
+}
+
+// Error joins the messages, for contexts such as import rows that report a
+// single string.
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// writeValidationErrors renders errs as a 400. The top-level "error" keeps
+// the shape writeJSONError clients already parse.
+func writeValidationErrors(w http.ResponseWriter, errs ValidationErrors) {
+	writeError(w, http.StatusBadRequest, errorResponse{Error: "validation failed", Errors: errs})
+}
+
+// stringRule declares the constraints on one string field. Values are
+// trimmed before they are checked.
+type stringRule struct {
+	label    string
+	required bool
+	// maxLen is in runes; 0 means unbounded.
+	maxLen int
+	// allowed, if set, must accept every rune; allowedDesc names them in the
+	// error message.
+	allowed     func(rune) bool
+	allowedDesc string
+}
+
+// check validates v against rule, recording failures under field, and
+// returns the trimmed value.
+func (rule stringRule) check(errs *ValidationErrors, field, v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		if rule.required {
+			errs.Add(field, rule.label+" is required")
+		}
+		return v
+	}
+	if rule.maxLen > 0 && utf8.RuneCountInString(v) > rule.maxLen {
+		errs.Add(field, fmt.Sprintf("%s exceeds %d characters", rule.label, rule.maxLen))
+	}
+	if rule.allowed != nil && strings.IndexFunc(v, func(r rune) bool { return !rule.allowed(r) }) >= 0 {
+		errs.Add(field, fmt.Sprintf("%s may only contain %s", rule.label, rule.allowedDesc))
+	}
+	return v
+}
+
+const (
+	maxDescriptionLen = 10000
+	maxTagLen         = 64
+	maxTagsPerItem    = 32
+)
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.'
+}
+
+// itemSchema is the single declaration of what a valid item looks like;
+// every write path validates through it.
+type itemSchema struct {
+	title, description, tag stringRule
+	maxTags                 int
+}
+
+func (a *App) itemSchema() itemSchema {
+	return itemSchema{
+		title:       stringRule{label: "title", required: true, maxLen: a.maxTitleLen},
+		description: stringRule{label: "description", maxLen: maxDescriptionLen},
+		tag: stringRule{
+			label:       "tag",
+			required:    true,
+			maxLen:      maxTagLen,
+			allowed:     isTagRune,
+			allowedDesc: "letters, digits, '-', '_' and '.'",
+		},
+		maxTags: maxTagsPerItem,
+	}
+}
+
+// itemInput is the writable fields of an item as a request carries them. A
+// nil field was absent from the request.
+type itemInput struct {
+	Title       *string
+	Description *string
+	Tags        []string
+}
+
+// validateItem checks in against the item schema, recording every failure
+// under prefix+field, and returns the normalized values. With partial set,
+// absent fields are left alone instead of being required.
+func (a *App) validateItem(errs *ValidationErrors, prefix string, in itemInput, partial bool) itemInput {
+	schema := a.itemSchema()
+	var out itemInput
+
+	if in.Title != nil || !partial {
+		var title string
+		if in.Title != nil {
+			title = *in.Title
+		}
+		title = schema.title.check(errs, prefix+"title", title)
+		out.Title = &title
+	}
+	if in.Description != nil {
+		description := schema.description.check(errs, prefix+"description", *in.Description)
+		out.Description = &description
+	}
+
+	for i, tag := range in.Tags {
+		schema.tag.check(errs, fmt.Sprintf("%stags[%d]", prefix, i), tag)
+	}
+	out.Tags = normalizeTags(in.Tags)
+	if len(out.Tags) > schema.maxTags {
+		errs.Add(prefix+"tags", fmt.Sprintf("at most %d tags are allowed", schema.maxTags))
+	}
+	return out
+}
+
+// parseCreatedAt validates an optional created_at override, used when
+// importing historical items. Empty means "now", which the insert supplies.
+func parseCreatedAt(errs *ValidationErrors, field, raw string) sql.NullTime {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return sql.NullTime{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		errs.Add(field, "created_at must be an RFC3339 timestamp")
+		return sql.NullTime{}
+	}
+	if t.After(time.Now()) {
+		errs.Add(field, "created_at must not be in the future")
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t, Valid: true}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+const confirmDeleteAllHeader = "X-Confirm-Delete-All"
+
+type deleteAllResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// handleDeleteAll wipes every item, soft-deleted ones included, and resets
+// the id sequences, for clearing dev and test databases between runs. It
+// never runs in production and only for an admin who confirms with
+// X-Confirm-Delete-All: yes. It lives on the admin listener when one is
+// configured.
+func (a *App) handleDeleteAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if a.production {
+		writeJSONError(w, http.StatusForbidden, "deleting all items is disabled in production")
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Header.Get(confirmDeleteAllHeader) != "yes" {
+		writeJSONError(w, http.StatusBadRequest, confirmDeleteAllHeader+": yes is required")
+		return
+	}
+
+	r, cancel := a.withQueryTimeout(r)
+	defer cancel()
+
+	var deleted int64
+	err := a.inTx(r.Context(), func(tx *sql.Tx) error {
+		return truncateItems(r.Context(), tx, &deleted)
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to delete all items", "err", err)
+		writeDBError(w, err, "failed to delete all items")
+		return
+	}
+
+	slog.WarnContext(r.Context(), "deleted all items", "count", deleted)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(deleteAllResponse{Deleted: deleted})
+}
+
+// truncateItems counts and then truncates items with everything keyed by
+// item id. The lock comes first so no insert slips in between the count
+// and the truncate.
+func truncateItems(ctx context.Context, tx *sql.Tx, deleted *int64) error {
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE items IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM items`).Scan(deleted); err != nil {
+		return err
+	}
+	// audit_log has no foreign key to items, but with ids restarting its
+	// old rows would be attributed to the new items.
+	_, err := tx.ExecContext(ctx, `TRUNCATE items, item_tags, tags, idempotency_keys, audit_log RESTART IDENTITY`)
+	return err
+}
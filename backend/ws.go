@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = wsPongWait * 9 / 10
+	wsMaxMessageSize = 512
+)
+
+// wsMessage is the JSON frame pushed to dashboard clients.
+type wsMessage struct {
+	Action string `json:"action"`
+	Item   Item   `json:"item"`
+}
+
+type wsClient struct {
+	conn  *websocket.Conn
+	send  chan wsMessage
+	owner sql.NullString
+	// closeCode is sent in the close frame once send is closed; the hub sets
+	// it before closing send.
+	closeCode int
+}
+
+// wsHub tracks connected WebSocket clients and broadcasts item events from
+// the broker to them. It loads each changed item once and filters by owner
+// in memory instead of querying per client.
+type wsHub struct {
+	app      *App
+	upgrader websocket.Upgrader
+
+	register   chan *wsClient
+	unregister chan *wsClient
+	clients    map[*wsClient]bool
+	// done is closed when run exits so clients never block on a dead hub.
+	done chan struct{}
+}
+
+func newWSHub(app *App, allowedOrigins []string) *wsHub {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		allowed[o] = true
+	}
+
+	return &wsHub{
+		app: app,
+		upgrader: websocket.Upgrader{
+			// Browsers always send Origin on WebSocket handshakes; hold them
+			// to the same allowlist as CORS. Non-browser clients omit it.
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || allowed[origin]
+			},
+		},
+		register:   make(chan *wsClient),
+		unregister: make(chan *wsClient),
+		clients:    make(map[*wsClient]bool),
+		done:       make(chan struct{}),
+	}
+}
+
+func (h *wsHub) run(ctx context.Context) {
+	events := h.app.events.subscribe()
+	defer h.app.events.unsubscribe(events)
+	defer close(h.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.closeAll()
+			return
+
+		case <-h.app.draining:
+			h.closeAll()
+			return
+
+		case c := <-h.register:
+			h.clients[c] = true
+
+		case c := <-h.unregister:
+			if h.clients[c] {
+				delete(h.clients, c)
+				close(c.send)
+			}
+
+		case ev := <-events:
+			if len(h.clients) == 0 {
+				continue
+			}
+			item, err := h.app.loadItemForEvent(ctx, ev.ID)
+			if err != nil {
+				slog.Error("failed to load item for websocket broadcast", "item_id", ev.ID, "err", err)
+				continue
+			}
+			msg := wsMessage{Action: ev.Action, Item: item}
+			for c := range h.clients {
+				if c.owner.Valid && c.owner.String != item.OwnerID {
+					continue
+				}
+				select {
+				case c.send <- msg:
+				default:
+					// Too slow to keep up; drop it rather than block the hub.
+					delete(h.clients, c)
+					close(c.send)
+				}
+			}
+		}
+	}
+}
+
+// closeAll tells every client the server is going away.
+func (h *wsHub) closeAll() {
+	for c := range h.clients {
+		c.closeCode = websocket.CloseGoingAway
+		close(c.send)
+	}
+}
+
+// loadItemForEvent reads an item regardless of owner or deletion state; the
+// hub applies per-client filtering itself.
+func (a *App) loadItemForEvent(ctx context.Context, id int64) (Item, error) {
+	var item Item
+	err := scanItem(a.db.QueryRowContext(ctx, `SELECT `+itemColumns+` FROM items WHERE id = $1`, id), &item)
+	return item, err
+}
+
+func (a *App) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := a.hub.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the HTTP error response.
+		return
+	}
+
+	c := &wsClient{conn: conn, send: make(chan wsMessage, 16), owner: ownerFilter(r), closeCode: websocket.CloseNormalClosure}
+	select {
+	case a.hub.register <- c:
+	case <-a.hub.done:
+		conn.Close()
+		return
+	}
+
+	go c.writePump()
+	c.readPump(a.hub)
+}
+
+// readPump consumes client frames so control frames (pong, close) are
+// processed, and unregisters the client once the connection drops.
+func (c *wsClient) readPump(h *wsHub) {
+	defer func() {
+		select {
+		case h.unregister <- c:
+		case <-h.done:
+		}
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *wsClient) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, ""))
+				return
+			}
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}